@@ -0,0 +1,96 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"itop-sla-exporter/internal/itop"
+	"itop-sla-exporter/internal/utils"
+)
+
+// backlogBuckets are the upper bounds, in days, of the open ticket age buckets; older tickets fall in ">30d"
+var backlogBuckets = []struct {
+	Label string
+	Days  float64
+}{
+	{"<1d", 1},
+	{"1-3d", 3},
+	{"3-7d", 7},
+	{"7-30d", 30},
+}
+
+// ageBucket returns the bucket of an age in days
+func ageBucket(days float64) string {
+	for _, b := range backlogBuckets {
+		if days < b.Days {
+			return b.Label
+		}
+	}
+	return ">30d"
+}
+
+// oldestKey identifies the oldest open ticket series of a team
+type oldestKey struct {
+	Class, Team, SLAType string
+}
+
+// backlogAges collects the age of the open tickets during a summary update
+type backlogAges struct {
+	now    time.Time
+	oldest map[oldestKey]float64
+}
+
+// newBacklogAges starts a collection; now must be in the frame of the ticket dates (itopNow)
+func newBacklogAges(now time.Time) *backlogAges {
+	return &backlogAges{now: now, oldest: make(map[oldestKey]float64)}
+}
+
+// add counts an open ticket in its age buckets. Raw age is counted in calendar days, business-hour age
+// in business days of the ticket's schedule (its average working day).
+func (b *backlogAges) add(t itop.Ticket, cal *utils.BusinessCalendar) {
+	if resolvedStatus(t.Status) || t.StartDate.IsZero() || !b.now.After(t.StartDate) {
+		return
+	}
+	prio := priorityLabel(t.Priority)
+	sched := cal.Schedule()
+	raw := b.now.Sub(t.StartDate)
+	backlogAgeCount.WithLabelValues(t.Class, t.Team, t.Service, prio, "raw", "", ageBucket(raw.Hours()/24)).Inc()
+	b.keepOldest(oldestKey{t.Class, t.Team, "raw"}, raw.Seconds())
+	if day := sched.WorkingDay(); day > 0 {
+		bh := cal.Duration(t.StartDate, b.now)
+		backlogAgeCount.WithLabelValues(t.Class, t.Team, t.Service, prio, "business-hour", sched.Name, ageBucket(float64(bh)/float64(day))).Inc()
+		b.keepOldest(oldestKey{t.Class, t.Team, "business-hour"}, bh.Seconds())
+	}
+}
+
+func (b *backlogAges) keepOldest(k oldestKey, age float64) {
+	if age > b.oldest[k] {
+		b.oldest[k] = age
+	}
+}
+
+// flush sets the oldest open ticket age of each team
+func (b *backlogAges) flush() {
+	for k, age := range b.oldest {
+		oldestOpenAge.WithLabelValues(k.Class, k.Team, k.SLAType).Set(age)
+	}
+}
+
+var (
+	backlogAgeCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "itop_ticket_backlog_age_count",
+			Help: "Number of open tickets by age bucket (<1d, 1-3d, 3-7d, 7-30d, >30d; business days for business-hour), by class, team, service, priority, sla_type, schedule, bucket.",
+		},
+		[]string{"class", "team", "service", "priority", "sla_type", "schedule", "bucket"},
+	)
+
+	oldestOpenAge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "itop_team_oldest_open_ticket_age_seconds",
+			Help: "Age of the oldest open ticket of a team, by class, team, sla_type.",
+		},
+		[]string{"class", "team", "sla_type"},
+	)
+)
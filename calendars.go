@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"itop-sla-exporter/internal/itop"
+	"itop-sla-exporter/internal/utils"
+)
+
+// Range precomputed by the business calendars; older or later timestamps use the day-by-day calculation
+const (
+	calendarHistory = 5 * 365 * 24 * time.Hour
+	calendarFuture  = 366 * 24 * time.Hour
+)
+
+// calendarSet lazily builds one BusinessCalendar per schedule and holiday calendar, so that every
+// ticket sharing them reuses the same precomputed calendar
+type calendarSet struct {
+	holidays  map[string]utils.Holidays // by holiday calendar name
+	from, to  time.Time
+	mu        sync.Mutex
+	calendars map[calendarKey]*utils.BusinessCalendar
+}
+
+type calendarKey struct {
+	schedule        *utils.Schedule
+	holidayCalendar string
+}
+
+func newCalendarSet(holidays map[string]utils.Holidays) *calendarSet {
+	now := time.Now().UTC()
+	return &calendarSet{
+		holidays:  holidays,
+		from:      now.Add(-calendarHistory),
+		to:        now.Add(calendarFuture),
+		calendars: make(map[calendarKey]*utils.BusinessCalendar),
+	}
+}
+
+// ForTicket returns the calendar of a ticket's schedule and holiday calendar
+func (cs *calendarSet) ForTicket(t itop.Ticket) *utils.BusinessCalendar {
+	s := scheduleFor(t)
+	return cs.For(s, holidayCalendarFor(t, s))
+}
+
+// For returns the calendar of a schedule and holiday calendar, building it on first use.
+// Ticket dates are parsed without a zone, so calendars use UTC like the dates themselves.
+func (cs *calendarSet) For(s *utils.Schedule, holidayCalendar string) *utils.BusinessCalendar {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	key := calendarKey{s, holidayCalendar}
+	cal, ok := cs.calendars[key]
+	if !ok {
+		cal = utils.NewBusinessCalendar(s, cs.holidays[holidayCalendar], time.UTC, cs.from, cs.to)
+		cs.calendars[key] = cal
+	}
+	return cal
+}
+
+var (
+	lastHolidayProblem   = make(map[string]string)
+	lastHolidayProblemMu sync.Mutex
+)
+
+// loadHolidayFile reads one holiday file; invalid entries are logged once per file
+func loadHolidayFile(path string) []itop.Holiday {
+	list, err := itop.LoadHolidayFile(path)
+	logHolidayProblem(path, err)
+	return list
+}
+
+// logHolidayProblem logs the problems of a holiday source, unless they were already logged
+func logHolidayProblem(source string, err error) {
+	lastHolidayProblemMu.Lock()
+	defer lastHolidayProblemMu.Unlock()
+	if err == nil {
+		delete(lastHolidayProblem, source)
+		return
+	}
+	if err.Error() != lastHolidayProblem[source] {
+		log.Printf("Holiday source: %v", err)
+		lastHolidayProblem[source] = err.Error()
+	}
+}
+
+type icsCacheEntry struct {
+	fetched  time.Time
+	holidays []itop.Holiday
+}
+
+var (
+	icsCache   = make(map[string]icsCacheEntry)
+	icsCacheMu sync.Mutex
+)
+
+// loadHolidayICS reads an ICS holiday source. Feeds served over HTTP are cached for refresh (ics_refresh_interval),
+// and the last good copy is kept when a refresh fails. The cache is not locked during the fetch.
+func loadHolidayICS(source string, refresh time.Duration, skipVerify bool) []itop.Holiday {
+	if !isRemoteICS(source) {
+		list, err := itop.LoadHolidayICS(source, false)
+		logHolidayProblem(source, err)
+		return list
+	}
+	icsCacheMu.Lock()
+	entry, ok := icsCache[source]
+	icsCacheMu.Unlock()
+	if ok && time.Since(entry.fetched) < refresh {
+		return entry.holidays
+	}
+	list, err := itop.LoadHolidayICS(source, skipVerify)
+	logHolidayProblem(source, err)
+	if err != nil && list == nil {
+		// keep serving the previous copy and retry at the next refresh
+		entry.fetched = time.Now()
+	} else {
+		entry = icsCacheEntry{fetched: time.Now(), holidays: list}
+	}
+	icsCacheMu.Lock()
+	icsCache[source] = entry
+	icsCacheMu.Unlock()
+	return entry.holidays
+}
+
+// handleHolidaysICS serves the effective holidays of a calendar (?calendar=name, default calendar otherwise)
+// as an iCalendar feed that agents can subscribe to
+func handleHolidaysICS(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("calendar")
+	if name == "" {
+		name = currentConfig().HolidayCalendars.Default
+	}
+	list, ok := holidayStore.Entries()[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown holiday calendar %q", name), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Write(itop.FormatICS(name, list))
+}
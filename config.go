@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"itop-sla-exporter/internal/utils"
+)
+
+// Defaults of the settings that used to be hardcoded
+const (
+	defaultConfigPath     = "config/business_hours.yaml"
+	defaultListenAddress  = ":9100"
+	defaultITopAPIVersion = "1.3"
+	defaultPollInterval   = "10s"
+)
+
+// Config is the exporter configuration: the YAML file, overridden by ITOP_EXPORTER_* environment
+// variables, themselves overridden by command-line flags (see configSettings)
+type Config struct {
+	// ListenAddress is the HTTP address of the exporter (default :9100)
+	ListenAddress string `yaml:"listen_address"`
+	// ITopAPIVersion is the iTop REST API version (default 1.3)
+	ITopAPIVersion string `yaml:"itop_api_version"`
+	// ITopTimezone is the IANA time zone of iTop's dates, which the REST API returns without one
+	// (default Local, the exporter's own zone)
+	ITopTimezone string         `yaml:"itop_timezone"`
+	itopLocation *time.Location // ITopTimezone, loaded by validateServerConfig
+	// PollIntervals sets how often the exporter polls iTop and its files
+	PollIntervals PollIntervalsConfig `yaml:"poll_intervals"`
+	WorkHours     WorkHoursConfig     `yaml:"work_hours"`
+	// HolidaysFile is where the holidays synced from iTop are stored (default holidays.txt)
+	HolidaysFile string                                  `yaml:"holidays_file"`
+	SLADeadlines map[string]map[string]SLADeadlineConfig `yaml:"sla_deadlines"`
+	// SLAEngine selects who decides compliance: "exporter" (default), "itop" or "both"
+	SLAEngine string `yaml:"sla_engine"`
+	// Named business-hour schedules; work_hours is used as the "default" schedule when empty
+	Schedules           map[string]ScheduleConfig `yaml:"schedules"`
+	DefaultSchedule     string                    `yaml:"default_schedule"`
+	ScheduleAssignments []ScheduleAssignment      `yaml:"schedule_assignments"`
+	// CoverageWindows imports iTop coverage windows as schedules for the services they cover
+	CoverageWindows CoverageWindowsConfig `yaml:"coverage_windows"`
+	// HolidayCalendars assigns named holiday calendars per organization, team or schedule
+	HolidayCalendars HolidayCalendarsConfig `yaml:"holiday_calendars"`
+	// Labels renames priority, urgency, impact and status codes in metric labels
+	Labels LabelsConfig `yaml:"labels"`
+	// PriorityMatrix recomputes priority from impact and urgency to flag overridden priorities
+	PriorityMatrix PriorityMatrixConfig `yaml:"priority_matrix"`
+	// SLAPolicy uses ticket history (priority changes) to decide which SLT applies
+	SLAPolicy SLAPolicyConfig `yaml:"sla_policy"`
+	// ResponseDefinition chooses per class what stops the time to response
+	// (assignment, public_log or status_change), e.g. {Incident: public_log}
+	ResponseDefinition map[string]string `yaml:"response_definition"`
+	// Reassignment exports reassignment, hand-off and time-per-team metrics from the team_id/agent_id history
+	Reassignment ReassignmentConfig `yaml:"reassignment"`
+	// OLA sets the internal targets each team must meet while it holds a ticket
+	OLA OLAConfig `yaml:"ola"`
+}
+
+// WorkHoursConfig is the single daily window of the default schedule
+type WorkHoursConfig struct {
+	Start       string   `yaml:"start"`
+	End         string   `yaml:"end"`
+	WorkingDays []string `yaml:"working_days"`
+}
+
+// SLADeadlineConfig holds response and resolve durations like "4h"
+type SLADeadlineConfig struct {
+	Response string `yaml:"response"`
+	Resolve  string `yaml:"resolve"`
+}
+
+// PollIntervalsConfig holds the polling intervals, as durations like "10s" or "5m" (all default to 10s)
+type PollIntervalsConfig struct {
+	// Tickets is the delay between two ticket fetches of a class
+	Tickets string `yaml:"tickets"`
+	// Summary is how often the /metrics summary is recomputed
+	Summary string `yaml:"summary"`
+	// HolidaySync is the delay between two holiday syncs from iTop
+	HolidaySync string `yaml:"holiday_sync"`
+	// HolidayFiles is how often holiday files are checked for changes
+	HolidayFiles string `yaml:"holiday_files"`
+}
+
+// configSetting is a config key that can also be set by flag or environment variable
+type configSetting struct {
+	key   string // YAML key
+	flag  string
+	env   string
+	usage string
+	field func(*Config) *string
+}
+
+var configSettings = []configSetting{
+	{"listen_address", "listen-address", "ITOP_EXPORTER_LISTEN_ADDRESS", "HTTP listen address (default " + defaultListenAddress + ")",
+		func(c *Config) *string { return &c.ListenAddress }},
+	{"itop_api_version", "itop-api-version", "ITOP_EXPORTER_ITOP_API_VERSION", "iTop REST API version (default " + defaultITopAPIVersion + ")",
+		func(c *Config) *string { return &c.ITopAPIVersion }},
+	{"itop_timezone", "itop-timezone", "ITOP_EXPORTER_ITOP_TIMEZONE", "time zone of iTop's dates (default Local)",
+		func(c *Config) *string { return &c.ITopTimezone }},
+	{"holidays_file", "holidays-file", "ITOP_EXPORTER_HOLIDAYS_FILE", "file the iTop holidays are synced to (default " + defaultHolidaysFile + ")",
+		func(c *Config) *string { return &c.HolidaysFile }},
+	{"poll_intervals.tickets", "poll-tickets", "ITOP_EXPORTER_POLL_TICKETS", "delay between ticket fetches (default " + defaultPollInterval + ")",
+		func(c *Config) *string { return &c.PollIntervals.Tickets }},
+	{"poll_intervals.summary", "poll-summary", "ITOP_EXPORTER_POLL_SUMMARY", "summary metrics update interval (default " + defaultPollInterval + ")",
+		func(c *Config) *string { return &c.PollIntervals.Summary }},
+	{"poll_intervals.holiday_sync", "poll-holiday-sync", "ITOP_EXPORTER_POLL_HOLIDAY_SYNC", "delay between holiday syncs from iTop (default " + defaultPollInterval + ")",
+		func(c *Config) *string { return &c.PollIntervals.HolidaySync }},
+	{"poll_intervals.holiday_files", "poll-holiday-files", "ITOP_EXPORTER_POLL_HOLIDAY_FILES", "holiday file change check interval (default " + defaultPollInterval + ")",
+		func(c *Config) *string { return &c.PollIntervals.HolidayFiles }},
+}
+
+// cliOptions are the config file path and the settings given by environment variable or flag
+type cliOptions struct {
+	ConfigPath string
+	Overrides  map[string]string // by YAML key
+}
+
+// parseCLI reads ITOP_EXPORTER_CONFIG and the other ITOP_EXPORTER_* variables, then the flags, which win
+func parseCLI(args []string) (cliOptions, error) {
+	opts := cliOptions{ConfigPath: defaultConfigPath, Overrides: make(map[string]string)}
+	if v := os.Getenv("ITOP_EXPORTER_CONFIG"); v != "" {
+		opts.ConfigPath = v
+	}
+	for _, s := range configSettings {
+		if v, ok := os.LookupEnv(s.env); ok {
+			opts.Overrides[s.key] = v
+		}
+	}
+	fs := flag.NewFlagSet("itop-sla-exporter", flag.ContinueOnError)
+	fs.StringVar(&opts.ConfigPath, "config", opts.ConfigPath, "path of the YAML config file (env ITOP_EXPORTER_CONFIG)")
+	values := make(map[string]*string)
+	for _, s := range configSettings {
+		values[s.flag] = fs.String(s.flag, "", s.usage+" (env "+s.env+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	if fs.NArg() > 0 {
+		return opts, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range configSettings {
+			if s.flag == f.Name {
+				opts.Overrides[s.key] = *values[s.flag]
+			}
+		}
+	})
+	return opts, nil
+}
+
+var (
+	config Config
+	// configMu guards config and schedules against a reload swapping them; readers hold RLock while using them
+	configMu sync.RWMutex
+)
+
+// currentConfig returns a copy of the running config for goroutines that do not hold configMu
+func currentConfig() Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config
+}
+
+// loadConfig reads and validates the config, then swaps it in. On error the running config is kept.
+func loadConfig(path string, overrides map[string]string) error {
+	cfg, compiled, hash, err := readConfig(path, overrides)
+	if err != nil {
+		configLastReloadSuccessful.Set(0)
+		return err
+	}
+	configMu.Lock()
+	config, schedules = cfg, compiled
+	configMu.Unlock()
+	configLastReloadSuccessful.Set(1)
+	configLastReloadSuccess.SetToCurrentTime()
+	configHash.Reset()
+	configHash.WithLabelValues(hash).Set(1)
+	return nil
+}
+
+// readConfig reads the YAML file strictly (unknown keys are errors), applies the overrides and validates the result.
+// It returns the config with its compiled schedules and the SHA-256 of the file.
+func readConfig(path string, overrides map[string]string) (Config, map[string]*utils.Schedule, string, error) {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, nil, "", err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.SetStrict(true)
+	if err := decoder.Decode(&cfg); err != nil && err != io.EOF {
+		return cfg, nil, "", fmt.Errorf("%s: %v", path, err)
+	}
+	for _, s := range configSettings {
+		if v, ok := overrides[s.key]; ok {
+			*s.field(&cfg) = v
+		}
+	}
+	if err := validateServerConfig(&cfg); err != nil {
+		return cfg, nil, "", err
+	}
+	switch cfg.SLAEngine {
+	case "":
+		cfg.SLAEngine = engineExporter
+	case engineExporter, engineITop, engineBoth:
+	default:
+		return cfg, nil, "", fmt.Errorf("invalid sla_engine %q (expected %s, %s or %s)", cfg.SLAEngine, engineExporter, engineITop, engineBoth)
+	}
+	if err := validateSLADeadlines(&cfg); err != nil {
+		return cfg, nil, "", err
+	}
+	compiled, err := compileSchedules(&cfg)
+	if err != nil {
+		return cfg, nil, "", err
+	}
+	if cfg.HolidaysFile == "" {
+		cfg.HolidaysFile = defaultHolidaysFile
+	}
+	if err := validateHolidayCalendars(&cfg.HolidayCalendars); err != nil {
+		return cfg, nil, "", err
+	}
+	if cfg.CoverageWindows.RefreshInterval == "" {
+		cfg.CoverageWindows.RefreshInterval = "10m"
+	}
+	if d, err := parseDuration(cfg.CoverageWindows.RefreshInterval); err != nil || d <= 0 {
+		return cfg, nil, "", fmt.Errorf("coverage_windows.refresh_interval: invalid duration %q (expected e.g. 10m)", cfg.CoverageWindows.RefreshInterval)
+	}
+	if err := validateLabels(&cfg.Labels); err != nil {
+		return cfg, nil, "", err
+	}
+	if err := validatePriorityMatrix(&cfg.PriorityMatrix); err != nil {
+		return cfg, nil, "", err
+	}
+	if err := validateSLAPolicy(&cfg.SLAPolicy); err != nil {
+		return cfg, nil, "", err
+	}
+	if err := validateResponseDefinitions(cfg.ResponseDefinition); err != nil {
+		return cfg, nil, "", err
+	}
+	if err := validateOLA(&cfg.OLA); err != nil {
+		return cfg, nil, "", err
+	}
+	sum := sha256.Sum256(data)
+	return cfg, compiled, hex.EncodeToString(sum[:]), nil
+}
+
+var apiVersionPattern = regexp.MustCompile(`^[0-9]+\.[0-9]+$`)
+
+// itopNow returns the current time in the frame of the ticket dates: iTop's wall clock, parsed as UTC
+func itopNow(cfg Config) time.Time {
+	loc := cfg.itopLocation
+	if loc == nil {
+		loc = time.Local
+	}
+	now := time.Now().In(loc)
+	return time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), now.Second(), now.Nanosecond(), time.UTC)
+}
+
+// itopUnix returns the Unix time of an iTop date, whose wall clock was parsed as UTC: the same wall clock
+// in iTop's time zone is the real instant
+func itopUnix(cfg Config, t time.Time) int64 {
+	loc := cfg.itopLocation
+	if loc == nil {
+		loc = time.Local
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc).Unix()
+}
+
+// validateServerConfig defaults and checks the listen address, iTop API version and poll intervals
+func validateServerConfig(cfg *Config) error {
+	if cfg.ListenAddress == "" {
+		cfg.ListenAddress = defaultListenAddress
+	}
+	if _, _, err := net.SplitHostPort(cfg.ListenAddress); err != nil {
+		return fmt.Errorf("listen_address: invalid address %q (expected host:port or :port)", cfg.ListenAddress)
+	}
+	if cfg.ITopAPIVersion == "" {
+		cfg.ITopAPIVersion = defaultITopAPIVersion
+	}
+	if !apiVersionPattern.MatchString(cfg.ITopAPIVersion) {
+		return fmt.Errorf("itop_api_version: invalid version %q (expected e.g. 1.3)", cfg.ITopAPIVersion)
+	}
+	if cfg.ITopTimezone == "" {
+		cfg.ITopTimezone = "Local"
+	}
+	loc, err := time.LoadLocation(cfg.ITopTimezone)
+	if err != nil {
+		return fmt.Errorf("itop_timezone: %v", err)
+	}
+	cfg.itopLocation = loc
+	intervals := []struct {
+		key string
+		v   *string
+	}{
+		{"poll_intervals.tickets", &cfg.PollIntervals.Tickets},
+		{"poll_intervals.summary", &cfg.PollIntervals.Summary},
+		{"poll_intervals.holiday_sync", &cfg.PollIntervals.HolidaySync},
+		{"poll_intervals.holiday_files", &cfg.PollIntervals.HolidayFiles},
+	}
+	for _, iv := range intervals {
+		key, v := iv.key, iv.v
+		if *v == "" {
+			*v = defaultPollInterval
+		}
+		if d, err := parseDuration(*v); err != nil || d <= 0 {
+			return fmt.Errorf("%s: invalid duration %q (expected e.g. 30s or 5m)", key, *v)
+		}
+	}
+	return nil
+}
+
+// validateSLADeadlines checks the durations of sla_deadlines, in a stable order for the error message
+func validateSLADeadlines(cfg *Config) error {
+	var keys []string
+	for k := range cfg.SLADeadlines {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var names []string
+		for name := range cfg.SLADeadlines[k] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			d := cfg.SLADeadlines[k][name]
+			if _, err := parseDuration(d.Response); err != nil {
+				return fmt.Errorf("sla_deadlines.%s.%s.response: invalid duration %q", k, name, d.Response)
+			}
+			if _, err := parseDuration(d.Resolve); err != nil {
+				return fmt.Errorf("sla_deadlines.%s.%s.resolve: invalid duration %q", k, name, d.Resolve)
+			}
+		}
+	}
+	return nil
+}
+
+// pollInterval returns a validated poll interval
+func pollInterval(v string) time.Duration {
+	d, _ := parseDuration(v)
+	return d
+}
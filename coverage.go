@@ -0,0 +1,108 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"itop-sla-exporter/internal/itop"
+	"itop-sla-exporter/internal/utils"
+)
+
+// CoverageWindowsConfig enables importing iTop CoverageWindow objects as schedules
+type CoverageWindowsConfig struct {
+	Enabled         bool   `yaml:"enabled"`
+	RefreshInterval string `yaml:"refresh_interval"`
+}
+
+var (
+	coverageSchedules map[string]*utils.Schedule                // by coverage window name
+	serviceCoverage   map[itop.CoverageKey]itop.ServiceCoverage // by customer and service name
+	coverageMu        sync.RWMutex
+)
+
+// coverageScheduleFor returns the imported coverage window schedule a customer's contract links to a service, if any
+func coverageScheduleFor(org, service string) *utils.Schedule {
+	coverageMu.RLock()
+	defer coverageMu.RUnlock()
+	cov, ok := serviceCoverage[itop.CoverageKey{Organization: org, Service: service}]
+	if !ok {
+		return nil
+	}
+	return coverageSchedules[cov.CoverageWindow]
+}
+
+// coverageHolidayCalendarFor returns the iTop HolidayCalendar a customer's contract links to a service, if any
+func coverageHolidayCalendarFor(org, service string) string {
+	coverageMu.RLock()
+	defer coverageMu.RUnlock()
+	return serviceCoverage[itop.CoverageKey{Organization: org, Service: service}].HolidayCalendar
+}
+
+// contractLinksNeeded reports whether the contract links are used: for coverage window schedules,
+// or for the holiday calendars linked to the contracts
+func contractLinksNeeded(cfg Config) bool {
+	return cfg.CoverageWindows.Enabled || cfg.HolidayCalendars.UseITopLinkage
+}
+
+// refreshCoverageWindows fetches the contract links (coverage window, holiday calendar) from iTop, and the
+// coverage windows themselves when they are imported as schedules, and swaps them in
+func refreshCoverageWindows(cfg Config) error {
+	var windows []itop.CoverageWindow
+	if cfg.CoverageWindows.Enabled {
+		var err error
+		if windows, err = itop.FetchCoverageWindows(); err != nil {
+			return err
+		}
+	}
+	links, err := itop.FetchServiceCoverage()
+	if err != nil {
+		return err
+	}
+	compiled := make(map[string]*utils.Schedule)
+	for _, cw := range windows {
+		s, err := coverageWindowSchedule(cw)
+		if err != nil {
+			log.Printf("Skipping coverage window %q: %v", cw.Name, err)
+			continue
+		}
+		compiled[cw.Name] = s
+	}
+	coverageMu.Lock()
+	coverageSchedules = compiled
+	serviceCoverage = links
+	coverageMu.Unlock()
+	return nil
+}
+
+// coverageWindowSchedule converts an iTop coverage window to a schedule
+func coverageWindowSchedule(cw itop.CoverageWindow) (*utils.Schedule, error) {
+	s := &utils.Schedule{Name: cw.Name}
+	for _, iv := range cw.Intervals {
+		wd, ok := weekdayNames[strings.ToLower(iv.Weekday)]
+		if !ok {
+			log.Printf("Coverage window %q: unknown weekday %q", cw.Name, iv.Weekday)
+			continue
+		}
+		w, err := utils.ParseTimeWindow(iv.StartTime + "-" + iv.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		s.Days[wd] = append(s.Days[wd], w)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// syncCoverageWindows periodically refreshes the imported coverage windows and contract links
+func syncCoverageWindows(interval time.Duration) {
+	for {
+		if err := refreshCoverageWindows(currentConfig()); err != nil {
+			log.Printf("Failed to fetch coverage windows: %v", err)
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"itop-sla-exporter/internal/itop"
+)
+
+// How far back the flow metrics go; older periods are dropped to bound the number of series
+const (
+	flowDays  = 30
+	flowWeeks = 12
+)
+
+// flowWindow holds the day (YYYY-MM-DD) and ISO week (YYYY-Www) periods the flow metrics are exported for
+type flowWindow struct {
+	days  map[string]bool
+	weeks map[string]bool
+}
+
+// newFlowWindow returns the periods ending with the one containing now. now must be iTop's wall clock (itopNow),
+// as the event dates are, so that each iTop day is counted from its own midnight.
+func newFlowWindow(now time.Time) flowWindow {
+	w := flowWindow{days: make(map[string]bool), weeks: make(map[string]bool)}
+	for i := 0; i < flowDays; i++ {
+		w.days[now.AddDate(0, 0, -i).Format("2006-01-02")] = true
+	}
+	for i := 0; i < flowWeeks; i++ {
+		w.weeks[isoWeek(now.AddDate(0, 0, -7*i))] = true
+	}
+	return w
+}
+
+func isoWeek(d time.Time) string {
+	year, week := d.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// add counts the opening, resolution and closure of a ticket in their day and week.
+// The net backlog change of a period is its opened minus resolved tickets.
+func (w flowWindow) add(t itop.Ticket) {
+	events := []struct {
+		name string
+		date time.Time
+		net  float64
+	}{
+		{"opened", t.StartDate, 1},
+		{"resolved", t.ResolutionDate, -1},
+		{"closed", t.CloseDate, 0},
+	}
+	for _, e := range events {
+		if e.date.IsZero() {
+			continue
+		}
+		periods := []struct{ period, date string }{{"day", e.date.Format("2006-01-02")}, {"week", isoWeek(e.date)}}
+		for _, p := range periods {
+			if (p.period == "day" && !w.days[p.date]) || (p.period == "week" && !w.weeks[p.date]) {
+				continue
+			}
+			ticketFlowCount.WithLabelValues(t.Class, t.Team, t.Service, e.name, p.period, p.date).Inc()
+			if e.net != 0 {
+				backlogNetChange.WithLabelValues(t.Class, t.Team, t.Service, p.period, p.date).Add(e.net)
+			}
+		}
+	}
+}
+
+var (
+	ticketFlowCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "itop_ticket_flow_count",
+			Help: "Number of tickets opened, resolved or closed in a day (last 30) or ISO week (last 12), by class, team, service, event, period (day, week), date.",
+		},
+		[]string{"class", "team", "service", "event", "period", "date"},
+	)
+
+	backlogNetChange = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "itop_ticket_backlog_net_change",
+			Help: "Tickets opened minus tickets resolved in a day (last 30) or ISO week (last 12), by class, team, service, period (day, week), date.",
+		},
+		[]string{"class", "team", "service", "period", "date"},
+	)
+)
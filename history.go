@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"itop-sla-exporter/internal/itop"
+	"itop-sla-exporter/internal/utils"
+)
+
+// Priority policies: which priority's SLT applies to a ticket whose priority changed
+const (
+	priorityPolicyInitial      = "initial"       // the priority the ticket was opened with
+	priorityPolicyHighest      = "highest"       // the most urgent priority it ever had
+	priorityPolicyFinal        = "final"         // its current priority
+	priorityPolicyTimeWeighted = "time-weighted" // each priority consumes its own SLT while it applies
+)
+
+// Reopen policies: where TTR starts for a ticket reopened after resolution
+const (
+	reopenPolicyCumulative = "cumulative" // from the original start to the final resolution
+	reopenPolicyRestart    = "restart"    // from the last reopen to the final resolution
+)
+
+// SLAPolicyConfig decides how ticket history affects the SLA. Leaving a policy empty skips the history fetch.
+type SLAPolicyConfig struct {
+	// Priority is initial, highest, final or time-weighted
+	Priority string `yaml:"priority"`
+	// Reopen is cumulative or restart
+	Reopen string `yaml:"reopen"`
+}
+
+// validateSLAPolicy checks the policy names
+func validateSLAPolicy(cfg *SLAPolicyConfig) error {
+	switch cfg.Priority {
+	case "", priorityPolicyInitial, priorityPolicyHighest, priorityPolicyFinal, priorityPolicyTimeWeighted:
+	default:
+		return fmt.Errorf("sla_policy.priority: invalid policy %q (expected %s, %s, %s or %s)", cfg.Priority,
+			priorityPolicyInitial, priorityPolicyHighest, priorityPolicyFinal, priorityPolicyTimeWeighted)
+	}
+	switch cfg.Reopen {
+	case "", reopenPolicyCumulative, reopenPolicyRestart:
+	default:
+		return fmt.Errorf("sla_policy.reopen: invalid policy %q (expected %s or %s)", cfg.Reopen, reopenPolicyCumulative, reopenPolicyRestart)
+	}
+	return nil
+}
+
+// historyAttributes returns the attributes of a class whose history the configured policies need
+// (called by the fetchers, outside configMu)
+func historyAttributes(class string) []string {
+	cfg := currentConfig()
+	var attrs []string
+	if cfg.SLAPolicy.Priority != "" {
+		attrs = append(attrs, "priority")
+	}
+	if cfg.SLAPolicy.Reopen != "" || responseDefinition(cfg, class) == responseStatusChange {
+		attrs = append(attrs, "status")
+	}
+	if cfg.Reassignment.Enabled {
+		attrs = append(attrs, "team_id", "agent_id")
+	} else if len(cfg.OLA.Targets) > 0 {
+		attrs = append(attrs, "team_id")
+	}
+	return attrs
+}
+
+// fetchTicketsWithHistory fetches the tickets of a class and, when a policy needs it, their attribute history.
+// The history is cached and only the new changes are fetched on each poll; without history (fetch error)
+// the tickets are evaluated with their current values.
+func fetchTicketsWithHistory(class string) []itop.Ticket {
+	fields := append(append([]string{}, stopwatchFields()...), responseFields(class)...)
+	tickets, err := itop.FetchTicketsByClass(class, fields...)
+	if err != nil {
+		log.Printf("Failed to fetch %s tickets: %v", class, err)
+	}
+	if attrs := historyAttributes(class); len(attrs) > 0 && len(tickets) > 0 {
+		if err := itop.AttachHistory(tickets, class, attrs...); err != nil {
+			log.Printf("Failed to fetch %s history: %v", class, err)
+		}
+	}
+	cfg := currentConfig()
+	if teamNamesNeeded(cfg) {
+		learnTeamNames(tickets)
+	}
+	if cfg.Labels.FromITop && len(tickets) > 0 {
+		noteLabelSamples(class, tickets)
+	}
+	return tickets
+}
+
+// priorityPeriod is a priority and the time it started to apply
+type priorityPeriod struct {
+	Priority string
+	From     time.Time
+}
+
+// priorityPeriods returns the priorities of a ticket over time, starting at its start date
+func priorityPeriods(t itop.Ticket) []priorityPeriod {
+	changes := t.Changes("priority")
+	if len(changes) == 0 {
+		return []priorityPeriod{{t.Priority, t.StartDate}}
+	}
+	periods := []priorityPeriod{{changes[0].OldValue, t.StartDate}}
+	for _, c := range changes {
+		from := c.Date
+		if from.Before(t.StartDate) {
+			from = t.StartDate
+		}
+		periods = append(periods, priorityPeriod{c.NewValue, from})
+	}
+	return periods
+}
+
+// applicablePriority returns the priority whose SLT applies under the initial, highest or final policy
+func applicablePriority(t itop.Ticket, policy string) string {
+	periods := priorityPeriods(t)
+	switch policy {
+	case priorityPolicyInitial:
+		return periods[0].Priority
+	case priorityPolicyHighest:
+		highest := periods[0].Priority
+		for _, p := range periods[1:] {
+			if morePressing(p.Priority, highest) {
+				highest = p.Priority
+			}
+		}
+		return highest
+	}
+	return t.Priority
+}
+
+// morePressing compares priority codes; iTop numbers them from 1 (critical) upwards
+func morePressing(a, b string) bool {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return na < nb
+}
+
+// applicableSLT returns the TTO and TTR targets of a ticket under the configured priority policy
+func applicableSLT(t itop.Ticket, cal *utils.BusinessCalendar) (tto, ttr time.Duration) {
+	policy := config.SLAPolicy.Priority
+	if policy == priorityPolicyTimeWeighted {
+		return timeWeightedSLT(t, cal)
+	}
+	priority := t.Priority
+	if policy != "" {
+		priority = applicablePriority(t, policy)
+	}
+	slt, err := itop.GetSLTDeadlineCached(t.Class, priority, t.Service)
+	if err != nil {
+		return 0, 0
+	}
+	return slt.TTO, slt.TTR
+}
+
+// timeWeightedSLT lets each priority period consume its share of that priority's SLT, in business time.
+// The effective target is the business time at which the shares reach 100%; periods whose priority
+// has no SLT consume nothing.
+func timeWeightedSLT(t itop.Ticket, cal *utils.BusinessCalendar) (tto, ttr time.Duration) {
+	periods := priorityPeriods(t)
+	slts := make([]itop.SLTDeadline, len(periods))
+	for i, p := range periods {
+		slts[i], _ = itop.GetSLTDeadlineCached(t.Class, p.Priority, t.Service)
+	}
+	return weightedSLT(periods, slts, cal)
+}
+
+// weightedSLT computes the time-weighted targets from the SLT of each period's priority (zero when it has none)
+func weightedSLT(periods []priorityPeriod, slts []itop.SLTDeadline, cal *utils.BusinessCalendar) (tto, ttr time.Duration) {
+	target := func(slt func(itop.SLTDeadline) time.Duration) time.Duration {
+		var elapsed, final time.Duration
+		consumed := 0.0
+		for i, p := range periods {
+			d := slt(slts[i])
+			if d > 0 {
+				final = d
+			}
+			if i+1 == len(periods) {
+				break
+			}
+			span := cal.Duration(p.From, periods[i+1].From)
+			if d > 0 {
+				share := float64(span) / float64(d)
+				if consumed+share >= 1 {
+					return elapsed + time.Duration((1-consumed)*float64(d))
+				}
+				consumed += share
+			}
+			elapsed += span
+		}
+		if final == 0 {
+			return 0
+		}
+		// the last period runs until the target is reached, with the last priority that has an SLT
+		return elapsed + time.Duration((1-consumed)*float64(final))
+	}
+	tto = target(func(s itop.SLTDeadline) time.Duration { return s.TTO })
+	ttr = target(func(s itop.SLTDeadline) time.Duration { return s.TTR })
+	return tto, ttr
+}
+
+// setPriorityChangeMetric exports the priority changes of a ticket with the priority its SLT was taken from
+func setPriorityChangeMetric(t itop.Ticket) {
+	policy := config.SLAPolicy.Priority
+	if policy == "" {
+		return
+	}
+	applicable := ""
+	if policy != priorityPolicyTimeWeighted {
+		applicable = priorityLabel(applicablePriority(t, policy))
+	}
+	ticketPriorityChanges.WithLabelValues(t.ID, t.Ref, t.Class, policy, applicable).Set(float64(len(t.Changes("priority"))))
+}
+
+// resolvedStatus reports whether a status code ends the TTR stopwatch
+func resolvedStatus(status string) bool {
+	return status == "resolved" || status == "closed"
+}
+
+// reopens returns the dates a ticket went from resolved or closed back to an open status
+func reopens(t itop.Ticket) []time.Time {
+	var dates []time.Time
+	for _, c := range t.Changes("status") {
+		if resolvedStatus(c.OldValue) && !resolvedStatus(c.NewValue) {
+			dates = append(dates, c.Date)
+		}
+	}
+	return dates
+}
+
+// resolveWindow returns when TTR starts and stops for a ticket under the reopen policy (zero end: not resolved).
+// A reopened ticket is only resolved again when its current status says so, at its last move to resolved;
+// iTop versions differ on whether resolution_date is kept or reset on reopen.
+func resolveWindow(t itop.Ticket) (start, end time.Time) {
+	start, end = t.StartDate, t.ResolutionDate
+	if config.SLAPolicy.Reopen == "" {
+		return start, end
+	}
+	reopened := reopens(t)
+	if len(reopened) == 0 {
+		return start, end
+	}
+	if config.SLAPolicy.Reopen == reopenPolicyRestart {
+		start = reopened[len(reopened)-1]
+	}
+	if !resolvedStatus(t.Status) {
+		return start, time.Time{}
+	}
+	for _, c := range t.Changes("status") {
+		if c.NewValue == "resolved" && !c.Date.Before(start) {
+			end = c.Date
+		}
+	}
+	if end.Before(start) {
+		end = time.Time{}
+	}
+	return start, end
+}
+
+// setReopenMetric exports the reopen count of a ticket
+func setReopenMetric(t itop.Ticket) {
+	if config.SLAPolicy.Reopen == "" {
+		return
+	}
+	ticketReopens.WithLabelValues(t.ID, t.Ref, t.Class).Set(float64(len(reopens(t))))
+}
+
+var (
+	priorityChangeCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "itop_ticket_priority_change_count",
+			Help: "Number of priority changes across tickets, by class and the sla_policy.priority applied (initial, highest, final, time-weighted).",
+		},
+		[]string{"class", "policy"},
+	)
+
+	ticketPriorityChanges = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "itop_ticket_priority_changes",
+			Help: "Number of priority changes of a ticket, by id, ref, class, policy and applicable_priority (empty for time-weighted).",
+		},
+		[]string{"id", "ref", "class", "policy", "applicable_priority"},
+	)
+
+	reopenedCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "itop_ticket_reopened_count",
+			Help: "Number of tickets reopened at least once after resolution, by class, team, service.",
+		},
+		[]string{"class", "team", "service"},
+	)
+
+	ticketReopens = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "itop_ticket_reopens",
+			Help: "Number of times a ticket was reopened after resolution, by id, ref, class.",
+		},
+		[]string{"id", "ref", "class"},
+	)
+)
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"itop-sla-exporter/internal/itop"
+	"itop-sla-exporter/internal/utils"
+)
+
+func TestWeightedSLT(t *testing.T) {
+	office, err := utils.ParseTimeWindow("08:00-17:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := utils.NewWeekdaySchedule("office", []utils.TimeWindow{office})
+	cal := utils.NewBusinessCalendar(s, nil, time.UTC, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC))
+	// priority → SLT; priority 4 has none
+	slts := map[string]itop.SLTDeadline{
+		"1": {TTO: 30 * time.Minute, TTR: 4 * time.Hour},
+		"2": {TTO: time.Hour, TTR: 8 * time.Hour},
+		"3": {TTO: 2 * time.Hour, TTR: 18 * time.Hour},
+	}
+	start := time.Date(2026, 1, 5, 8, 0, 0, 0, time.UTC) // Monday
+	change := func(from, to string, at time.Time) itop.AttributeChange {
+		return itop.AttributeChange{Attribute: "priority", OldValue: from, NewValue: to, Date: at}
+	}
+	tests := []struct {
+		name     string
+		priority string
+		history  []itop.AttributeChange
+		tto, ttr time.Duration
+	}{
+		{
+			name:     "single period",
+			priority: "2",
+			tto:      time.Hour, ttr: 8 * time.Hour,
+		},
+		{
+			// 5h of priority 3 consume 5/18 of its TTR (and all of its TTO), the rest runs on priority 1
+			name:     "escalation part-way through",
+			priority: "1",
+			history:  []itop.AttributeChange{change("3", "1", start.Add(5*time.Hour))},
+			tto:      2 * time.Hour, ttr: 5*time.Hour + time.Duration((1-5.0/18)*float64(4*time.Hour)),
+		},
+		{
+			// Monday 08:00 to Tuesday 08:00 is 9 business hours: half of priority 3's TTR
+			name:     "escalation after off-hours",
+			priority: "1",
+			history:  []itop.AttributeChange{change("3", "1", start.Add(24*time.Hour))},
+			tto:      2 * time.Hour, ttr: 9*time.Hour + 2*time.Hour,
+		},
+		{
+			name:     "period without SLT consumes nothing",
+			priority: "2",
+			history:  []itop.AttributeChange{change("4", "2", start.Add(4*time.Hour))},
+			tto:      4*time.Hour + time.Hour, ttr: 4*time.Hour + 8*time.Hour,
+		},
+		{
+			// the last priority without SLT runs on with the previous priority's SLT
+			name:     "last period without SLT",
+			priority: "4",
+			history:  []itop.AttributeChange{change("2", "4", start.Add(4*time.Hour))},
+			tto:      time.Hour, ttr: 8 * time.Hour,
+		},
+		{
+			name:     "no SLT at all",
+			priority: "4",
+			tto:      0, ttr: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticket := itop.Ticket{Priority: tt.priority, StartDate: start, History: tt.history}
+			periods := priorityPeriods(ticket)
+			list := make([]itop.SLTDeadline, len(periods))
+			for i, p := range periods {
+				list[i] = slts[p.Priority]
+			}
+			tto, ttr := weightedSLT(periods, list, cal)
+			if tto != tt.tto || ttr != tt.ttr {
+				t.Errorf("weightedSLT = %v, %v; want %v, %v", tto, ttr, tt.tto, tt.ttr)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"itop-sla-exporter/internal/itop"
+	"itop-sla-exporter/internal/utils"
+)
+
+// defaultHolidaysFile is the file the iTop holiday sync writes to when holidays_file is not set
+const defaultHolidaysFile = "holidays.txt"
+
+// defaultHolidayCalendarName is the implicit calendar (holidays synced from iTop) used when none are configured
+const defaultHolidayCalendarName = "default"
+
+// itopHolidayCalendarPrefix names the per-iTop-HolidayCalendar sets used for tickets whose contract links a calendar
+const itopHolidayCalendarPrefix = "itop:"
+
+// HolidayCalendarsConfig defines named holiday calendars and which tickets use them
+type HolidayCalendarsConfig struct {
+	Default     string                           `yaml:"default"`
+	Calendars   map[string]HolidayCalendarConfig `yaml:"calendars"`
+	Assignments []HolidayCalendarAssignment      `yaml:"assignments"`
+	// ICSRefreshInterval is how long an ICS feed served over HTTP is cached (default 1h)
+	ICSRefreshInterval string `yaml:"ics_refresh_interval"`
+	// ICSInsecureSkipVerify accepts any certificate from HTTPS ICS feeds, e.g. an internal CA the host does not trust
+	ICSInsecureSkipVerify bool `yaml:"ics_insecure_skip_verify"`
+	// UseITopLinkage gives a ticket the iTop HolidayCalendar its customer contract links to its service,
+	// independently of coverage_windows. Without it (and without assignments) every ticket uses the default
+	// calendar, which mixes the holidays of all iTop HolidayCalendars unless itop_calendars narrows it.
+	UseITopLinkage bool `yaml:"use_itop_linkage"`
+}
+
+// HolidayCalendarConfig lists the holiday sources of one calendar
+type HolidayCalendarConfig struct {
+	// ITop includes the holidays synced from iTop
+	ITop bool `yaml:"itop"`
+	// ITopCalendars keeps only the synced holidays of these iTop HolidayCalendar names; empty keeps all
+	ITopCalendars []string `yaml:"itop_calendars"`
+	// Files are holiday files (plain, CSV, YAML or .ics)
+	Files []string `yaml:"files"`
+	// ICS are iCalendar feeds (local path or http(s) URL), e.g. the HR holiday calendar
+	ICS []string `yaml:"ics"`
+	// Names keeps only the file entries of these calendar names ("calendar:" in the file); empty keeps all
+	Names []string `yaml:"names"`
+}
+
+// HolidayCalendarAssignment maps tickets to a holiday calendar; all fields set on a rule must match
+type HolidayCalendarAssignment struct {
+	Organization string `yaml:"organization"`
+	Team         string `yaml:"team"`
+	Schedule     string `yaml:"schedule"`
+	Calendar     string `yaml:"calendar"`
+}
+
+// validateHolidayCalendars defaults the calendar section to the iTop holidays and checks references
+func validateHolidayCalendars(cfg *HolidayCalendarsConfig) error {
+	if len(cfg.Calendars) == 0 {
+		cfg.Calendars = map[string]HolidayCalendarConfig{defaultHolidayCalendarName: {ITop: true}}
+		if cfg.Default == "" {
+			cfg.Default = defaultHolidayCalendarName
+		}
+	}
+	for name, c := range cfg.Calendars {
+		if !c.ITop && len(c.Files) == 0 && len(c.ICS) == 0 {
+			return fmt.Errorf("holiday_calendars.calendars.%s: no holiday source (set itop, files or ics)", name)
+		}
+		if !c.ITop && len(c.ITopCalendars) > 0 {
+			return fmt.Errorf("holiday_calendars.calendars.%s: itop_calendars requires itop: true", name)
+		}
+		if strings.HasPrefix(name, itopHolidayCalendarPrefix) {
+			return fmt.Errorf("holiday_calendars.calendars.%s: names starting with %q are reserved", name, itopHolidayCalendarPrefix)
+		}
+	}
+	if cfg.Default == "" {
+		return fmt.Errorf("holiday_calendars.default is required when calendars are defined")
+	}
+	if cfg.ICSRefreshInterval == "" {
+		cfg.ICSRefreshInterval = "1h"
+	}
+	if d, err := parseDuration(cfg.ICSRefreshInterval); err != nil || d <= 0 {
+		return fmt.Errorf("holiday_calendars.ics_refresh_interval: invalid duration %q (expected e.g. 1h)", cfg.ICSRefreshInterval)
+	}
+	if _, ok := cfg.Calendars[cfg.Default]; !ok {
+		return fmt.Errorf("holiday_calendars.default: calendar %q is not defined", cfg.Default)
+	}
+	for i, a := range cfg.Assignments {
+		if _, ok := cfg.Calendars[a.Calendar]; !ok {
+			return fmt.Errorf("holiday_calendars.assignments[%d]: calendar %q is not defined", i, a.Calendar)
+		}
+	}
+	return nil
+}
+
+// holidayCalendarFor returns the holiday calendar name of a ticket whose business hours follow sched:
+// the first matching assignment, else the iTop HolidayCalendar linked to its service's contract
+// (with use_itop_linkage), else the default calendar
+func holidayCalendarFor(t itop.Ticket, sched *utils.Schedule) string {
+	for _, a := range config.HolidayCalendars.Assignments {
+		if a.matches(t, sched) {
+			return a.Calendar
+		}
+	}
+	if config.HolidayCalendars.UseITopLinkage {
+		if name := coverageHolidayCalendarFor(t.Organization, t.Service); name != "" {
+			return itopHolidayCalendarPrefix + name
+		}
+	}
+	return config.HolidayCalendars.Default
+}
+
+func (a HolidayCalendarAssignment) matches(t itop.Ticket, sched *utils.Schedule) bool {
+	if a.Organization == "" && a.Team == "" && a.Schedule == "" {
+		return false
+	}
+	if a.Organization != "" && !strings.EqualFold(a.Organization, t.Organization) {
+		return false
+	}
+	if a.Team != "" && !strings.EqualFold(a.Team, t.Team) {
+		return false
+	}
+	if a.Schedule != "" && a.Schedule != sched.Name {
+		return false
+	}
+	return true
+}
+
+// loadHolidayCalendarEntries reads every holiday source once and returns the holidays of each configured
+// calendar, plus one "itop:<name>" calendar per iTop HolidayCalendar found in the synced holidays
+func loadHolidayCalendarEntries(cfg Config) map[string][]itop.Holiday {
+	files := make(map[string][]itop.Holiday)
+	read := func(path string) []itop.Holiday {
+		if list, ok := files[path]; ok {
+			return list
+		}
+		list := loadHolidayFile(path)
+		files[path] = list
+		return list
+	}
+	refresh, _ := parseDuration(cfg.HolidayCalendars.ICSRefreshInterval)
+	calendars := make(map[string][]itop.Holiday)
+	for name, c := range cfg.HolidayCalendars.Calendars {
+		var list []itop.Holiday
+		if c.ITop {
+			for _, h := range read(cfg.HolidaysFile) {
+				if len(c.ITopCalendars) == 0 || containsFold(c.ITopCalendars, h.Calendar) {
+					list = append(list, h)
+				}
+			}
+		}
+		var fromFiles []itop.Holiday
+		for _, path := range c.Files {
+			fromFiles = append(fromFiles, read(path)...)
+		}
+		for _, source := range c.ICS {
+			fromFiles = append(fromFiles, loadHolidayICS(source, refresh, cfg.HolidayCalendars.ICSInsecureSkipVerify)...)
+		}
+		for _, h := range fromFiles {
+			if len(c.Names) == 0 || containsFold(c.Names, h.Calendar) {
+				list = append(list, h)
+			}
+		}
+		calendars[name] = list
+	}
+	for _, h := range read(cfg.HolidaysFile) {
+		if h.Calendar != "" {
+			name := itopHolidayCalendarPrefix + h.Calendar
+			calendars[name] = append(calendars[name], h)
+		}
+	}
+	return calendars
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"itop-sla-exporter/internal/itop"
+	"itop-sla-exporter/internal/utils"
+)
+
+// HolidayStore keeps the holidays of every calendar in memory. It is rebuilt when the iTop sync writes
+// new holidays or a holiday file changes; the maps it returns are replaced, never modified, so callers
+// share them read-only.
+type HolidayStore struct {
+	mu      sync.RWMutex
+	entries map[string][]itop.Holiday // by calendar name
+	sets    map[string]utils.Holidays
+	stamps  map[string]fileStamp // holiday files as they were when loaded
+	loaded  time.Time
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+var holidayStore = &HolidayStore{}
+
+// Reload reads every holiday source and swaps the in-memory calendars. It works on a copy of the config,
+// so that fetching a remote ICS feed does not hold configMu (and stall a config reload and its readers);
+// callers must not hold configMu either.
+func (s *HolidayStore) Reload() {
+	cfg := currentConfig()
+	stamps := make(map[string]fileStamp)
+	for _, path := range holidayFilePaths(cfg) {
+		stamps[path] = statHolidayFile(path)
+	}
+	entries := loadHolidayCalendarEntries(cfg)
+	sets := make(map[string]utils.Holidays, len(entries))
+	for name, list := range entries {
+		sets[name] = itop.HolidaysToSet(list)
+	}
+	s.mu.Lock()
+	s.entries, s.sets, s.stamps, s.loaded = entries, sets, stamps, time.Now()
+	s.mu.Unlock()
+}
+
+// Entries returns the holidays of each calendar
+func (s *HolidayStore) Entries() map[string][]itop.Holiday {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.entries
+}
+
+// Sets returns the holiday set of each calendar, as used by business-hour calculations
+func (s *HolidayStore) Sets() map[string]utils.Holidays {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sets
+}
+
+// Watch reloads the store whenever a holiday file changes, or an ICS feed served over HTTP is due for refresh
+func (s *HolidayStore) Watch(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		if s.changed() {
+			log.Println("Holiday sources changed, reloading holidays")
+			s.Reload()
+		}
+	}
+}
+
+func (s *HolidayStore) changed() bool {
+	s.mu.RLock()
+	stamps, loaded := s.stamps, s.loaded
+	s.mu.RUnlock()
+	cfg := currentConfig()
+	paths := holidayFilePaths(cfg)
+	if len(paths) != len(stamps) {
+		return true
+	}
+	for _, path := range paths {
+		if old, ok := stamps[path]; !ok || statHolidayFile(path) != old {
+			return true
+		}
+	}
+	refresh, _ := parseDuration(cfg.HolidayCalendars.ICSRefreshInterval)
+	return hasRemoteICS(cfg) && time.Since(loaded) >= refresh
+}
+
+// holidayFilePaths lists the local holiday files of the configured calendars, each once: calendars may
+// share a file (filtered by names), and changed compares the list with the stamps map
+func holidayFilePaths(cfg Config) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	add(cfg.HolidaysFile)
+	for _, c := range cfg.HolidayCalendars.Calendars {
+		for _, path := range c.Files {
+			add(path)
+		}
+		for _, source := range c.ICS {
+			if !isRemoteICS(source) {
+				add(source)
+			}
+		}
+	}
+	return paths
+}
+
+func hasRemoteICS(cfg Config) bool {
+	for _, c := range cfg.HolidayCalendars.Calendars {
+		for _, source := range c.ICS {
+			if isRemoteICS(source) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isRemoteICS(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// statHolidayFile returns the zero stamp for a missing file, so that its creation is noticed
+func statHolidayFile(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
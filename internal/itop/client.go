@@ -7,9 +7,13 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
 )
 
+// APIVersion is the iTop REST API version sent with every request (itop_api_version in the exporter config)
+var APIVersion = "1.3"
+
 type ITopClient struct {
 	BaseURL  string
 	Username string
@@ -50,3 +54,19 @@ func (c *ITopClient) Post(operation string, params map[string]interface{}) ([]by
 	}
 	return body, err
 }
+
+// clientFromEnv builds a client from ITOP_API_URL, ITOP_API_USER and ITOP_API_PWD; ok is false when one is missing
+func clientFromEnv() (client ITopClient, ok bool) {
+	baseURL := os.Getenv("ITOP_API_URL")
+	username := os.Getenv("ITOP_API_USER")
+	password := os.Getenv("ITOP_API_PWD")
+	if baseURL == "" || username == "" || password == "" {
+		return ITopClient{}, false
+	}
+	return ITopClient{
+		BaseURL:  baseURL,
+		Username: username,
+		Password: password,
+		Version:  APIVersion,
+	}, true
+}
@@ -0,0 +1,179 @@
+package itop
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CoverageWindow is an iTop CoverageWindow with its weekly intervals
+type CoverageWindow struct {
+	ID        string
+	Name      string
+	Intervals []CoverageInterval
+}
+
+// CoverageInterval is one CoverageWindowInterval; times are "HH:MM"
+type CoverageInterval struct {
+	Weekday   string // monday ... sunday
+	StartTime string
+	EndTime   string
+}
+
+// FetchCoverageWindows fetches every CoverageWindow with its interval_list
+func FetchCoverageWindows() ([]CoverageWindow, error) {
+	client, ok := clientFromEnv()
+	if !ok {
+		return nil, fmt.Errorf("missing iTop API environment variables for coverage window fetch")
+	}
+	params := map[string]interface{}{
+		"class":         "CoverageWindow",
+		"key":           "SELECT CoverageWindow",
+		"output_fields": "name,interval_list",
+	}
+	body, err := client.Post("core/get", params)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Objects map[string]struct {
+			Key    json.Number `json:"key"`
+			Fields struct {
+				Name         string `json:"name"`
+				IntervalList []struct {
+					Weekday   string `json:"weekday"`
+					StartTime string `json:"start_time"`
+					EndTime   string `json:"end_time"`
+				} `json:"interval_list"`
+			} `json:"fields"`
+		} `json:"objects"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	var windows []CoverageWindow
+	for _, obj := range result.Objects {
+		cw := CoverageWindow{ID: obj.Key.String(), Name: obj.Fields.Name}
+		for _, iv := range obj.Fields.IntervalList {
+			start, err := coverageTime(iv.StartTime)
+			if err != nil {
+				return nil, fmt.Errorf("coverage window %q: %v", cw.Name, err)
+			}
+			end, err := coverageTime(iv.EndTime)
+			if err != nil {
+				return nil, fmt.Errorf("coverage window %q: %v", cw.Name, err)
+			}
+			cw.Intervals = append(cw.Intervals, CoverageInterval{
+				Weekday:   strings.ToLower(iv.Weekday),
+				StartTime: start,
+				EndTime:   end,
+			})
+		}
+		windows = append(windows, cw)
+	}
+	return windows, nil
+}
+
+// ServiceCoverage is the coverage window and holiday calendar a customer contract applies to a service
+type ServiceCoverage struct {
+	CoverageWindow  string
+	HolidayCalendar string
+}
+
+// CoverageKey identifies the services of one customer: two customers' contracts may cover the same
+// service with different windows
+type CoverageKey struct {
+	Organization string // customer org_id_friendlyname
+	Service      string // service name
+}
+
+// FetchServiceCoverage returns the coverage per customer and service, read from the customer contracts:
+// a value set on the contract/service link wins over the contract's own value. When several contracts of
+// a customer cover the same service, the oldest contract (lowest id) wins.
+func FetchServiceCoverage() (map[CoverageKey]ServiceCoverage, error) {
+	client, ok := clientFromEnv()
+	if !ok {
+		return nil, fmt.Errorf("missing iTop API environment variables for coverage window fetch")
+	}
+	params := map[string]interface{}{
+		"class":         "CustomerContract",
+		"key":           "SELECT CustomerContract",
+		"output_fields": "org_id_friendlyname,coveragewindow_id_friendlyname,holidaycalendar_id_friendlyname,services_list",
+	}
+	body, err := client.Post("core/get", params)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Objects map[string]struct {
+			Key    json.Number `json:"key"`
+			Fields struct {
+				Organization    string `json:"org_id_friendlyname"`
+				CoverageWindow  string `json:"coveragewindow_id_friendlyname"`
+				HolidayCalendar string `json:"holidaycalendar_id_friendlyname"`
+				ServicesList    []struct {
+					ServiceName     string `json:"service_name"`
+					CoverageWindow  string `json:"coveragewindow_id_friendlyname"`
+					HolidayCalendar string `json:"holidaycalendar_id_friendlyname"`
+				} `json:"services_list"`
+			} `json:"fields"`
+		} `json:"objects"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	// objects come in map order: walk the contracts by id so that the same contract always wins
+	type contract struct {
+		id  int
+		key string
+	}
+	contracts := make([]contract, 0, len(result.Objects))
+	for k, obj := range result.Objects {
+		id, _ := strconv.Atoi(obj.Key.String())
+		contracts = append(contracts, contract{id, k})
+	}
+	sort.Slice(contracts, func(i, j int) bool { return contracts[i].id < contracts[j].id })
+	byService := make(map[CoverageKey]ServiceCoverage)
+	for _, c := range contracts {
+		obj := result.Objects[c.key]
+		for _, svc := range obj.Fields.ServicesList {
+			cov := ServiceCoverage{CoverageWindow: svc.CoverageWindow, HolidayCalendar: svc.HolidayCalendar}
+			if cov.CoverageWindow == "" {
+				cov.CoverageWindow = obj.Fields.CoverageWindow
+			}
+			if cov.HolidayCalendar == "" {
+				cov.HolidayCalendar = obj.Fields.HolidayCalendar
+			}
+			key := CoverageKey{Organization: obj.Fields.Organization, Service: svc.ServiceName}
+			if _, seen := byService[key]; seen {
+				continue
+			}
+			if svc.ServiceName != "" && (cov.CoverageWindow != "" || cov.HolidayCalendar != "") {
+				byService[key] = cov
+			}
+		}
+	}
+	return byService, nil
+}
+
+// coverageTime converts an interval time to "HH:MM". iTop stores it either as "HH:MM" or as decimal hours ("8.50" = 08:30).
+func coverageTime(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, ":") {
+		parts := strings.Split(s, ":")
+		h, err1 := strconv.Atoi(parts[0])
+		m, err2 := strconv.Atoi(parts[1])
+		if err1 != nil || err2 != nil {
+			return "", fmt.Errorf("invalid interval time %q", s)
+		}
+		return fmt.Sprintf("%02d:%02d", h, m), nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 || f > 24 {
+		return "", fmt.Errorf("invalid interval time %q", s)
+	}
+	minutes := int(f*60 + 0.5)
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60), nil
+}
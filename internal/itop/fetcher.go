@@ -3,10 +3,21 @@ package itop
 import (
 	"log"
 	"os"
+	"strings"
 )
 
-// FetchTicketsByClass fetches tickets for a single class only
-func FetchTicketsByClass(class string) ([]Ticket, error) {
+// ticketOutputFields are the attributes always requested for a ticket
+const ticketOutputFields = "id,ref,title,origin,status,priority,urgency,impact,service_id,service_name,servicesubcategory_name,agent_id,agent_id_friendlyname,team_id,team_id_friendlyname,caller_id_friendlyname,org_id_friendlyname,start_date,assignment_date,resolution_date,close_date,sla_tto_passed,sla_ttr_passed"
+
+// PublicLogOutputField is the caselog read for the first agent entry (Ticket.FirstAgentLog)
+const PublicLogOutputField = "public_log"
+
+// StopwatchOutputFields are the TTO/TTR stopwatch attributes used when iTop is the SLA engine, besides the
+// sla_tto_passed/sla_ttr_passed flags: the 75% escalation deadlines of the standard Incident/UserRequest model
+var StopwatchOutputFields = []string{"tto_escalation_deadline", "ttr_escalation_deadline"}
+
+// FetchTicketsByClass fetches tickets for a single class only, optionally requesting extra output fields
+func FetchTicketsByClass(class string, extraFields ...string) ([]Ticket, error) {
 	baseURL := os.Getenv("ITOP_API_URL")
 	username := os.Getenv("ITOP_API_USER")
 	password := os.Getenv("ITOP_API_PWD")
@@ -18,12 +29,16 @@ func FetchTicketsByClass(class string) ([]Ticket, error) {
 		BaseURL:  baseURL,
 		Username: username,
 		Password: password,
-		Version:  "1.3",
+		Version:  APIVersion,
+	}
+	outputFields := ticketOutputFields
+	if len(extraFields) > 0 {
+		outputFields += "," + strings.Join(extraFields, ",")
 	}
 	params := map[string]interface{}{
 		"class":         class,
 		"key":           "SELECT " + class,
-		"output_fields": "id,ref,title,origin,status,priority,urgency,impact,service_id,service_name,servicesubcategory_name,agent_id,agent_id_friendlyname,team_id,team_id_friendlyname,caller_id_friendlyname,start_date,assignment_date,resolution_date,sla_tto_passed,sla_ttr_passed",
+		"output_fields": outputFields,
 	}
 	resp, err := client.Post("core/get", params)
 	if err != nil {
@@ -50,7 +65,7 @@ func FetchTickets() ([]Ticket, error) {
 		BaseURL:  baseURL,
 		Username: username,
 		Password: password,
-		Version:  "1.3",
+		Version:  APIVersion,
 	}
 	classes := []string{"Incident", "UserRequest"}
 	var allTickets []Ticket
@@ -58,7 +73,7 @@ func FetchTickets() ([]Ticket, error) {
 		params := map[string]interface{}{
 			"class":         class,
 			"key":           "SELECT " + class,
-			"output_fields": "id,ref,title,origin,status,priority,urgency,impact,service_id,service_name,servicesubcategory_name,agent_id,agent_id_friendlyname,team_id,team_id_friendlyname,caller_id_friendlyname,start_date,assignment_date,resolution_date,sla_tto_passed,sla_ttr_passed",
+			"output_fields": ticketOutputFields,
 		}
 		resp, err := client.Post("core/get", params)
 		if err != nil {
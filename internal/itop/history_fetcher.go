@@ -0,0 +1,178 @@
+package itop
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AttributeChange is one change of a ticket attribute recorded in iTop's history
+type AttributeChange struct {
+	Attribute string // attcode, e.g. "priority"
+	OldValue  string
+	NewValue  string
+	Date      time.Time
+	opID      int // breaks ties between changes made in the same second
+}
+
+// historyCache holds the changes already fetched for a class. Change ops only ever get appended,
+// so each fetch asks for the ops after the last one seen.
+type historyCache struct {
+	attributes string // the tracked attributes; another set starts the cache over
+	lastOp     int
+	changes    map[string][]AttributeChange // by ticket id, oldest first
+}
+
+var (
+	historyCaches   = make(map[string]*historyCache) // by class
+	historyCachesMu sync.Mutex
+)
+
+// FetchAttributeChanges returns the history (CMDBChangeOpSetAttributeScalar) of some attributes of a class,
+// by ticket id, oldest change first. Only the changes recorded since the previous call are fetched from iTop;
+// on error the cache is left as it was.
+func FetchAttributeChanges(class string, attributes ...string) (map[string][]AttributeChange, error) {
+	sorted := append([]string{}, attributes...)
+	sort.Strings(sorted)
+	attrKey := strings.Join(sorted, ",")
+
+	historyCachesMu.Lock()
+	defer historyCachesMu.Unlock()
+	cache := historyCaches[class]
+	if cache == nil || cache.attributes != attrKey {
+		cache = &historyCache{attributes: attrKey, changes: make(map[string][]AttributeChange)}
+		historyCaches[class] = cache
+	}
+	fetched, lastOp, err := fetchAttributeChangesAfter(class, sorted, cache.lastOp)
+	if err != nil {
+		return nil, err
+	}
+	for id, list := range fetched {
+		// copy: tickets from an earlier fetch still hold the old slice
+		merged := append(append([]AttributeChange{}, cache.changes[id]...), list...)
+		sortChanges(merged)
+		cache.changes[id] = merged
+	}
+	if lastOp > cache.lastOp {
+		cache.lastOp = lastOp
+	}
+	changes := make(map[string][]AttributeChange, len(cache.changes))
+	for id, list := range cache.changes {
+		changes[id] = list
+	}
+	return changes, nil
+}
+
+// forgetHistory drops the cached changes of tickets that are no longer returned by iTop (deleted tickets)
+func forgetHistory(class string, keep map[string]bool) {
+	historyCachesMu.Lock()
+	defer historyCachesMu.Unlock()
+	if cache := historyCaches[class]; cache != nil {
+		for id := range cache.changes {
+			if !keep[id] {
+				delete(cache.changes, id)
+			}
+		}
+	}
+}
+
+// fetchAttributeChangesAfter fetches the changes of some attributes of a class recorded after the change op
+// afterOp, by ticket id, with the highest op id seen
+func fetchAttributeChangesAfter(class string, attributes []string, afterOp int) (map[string][]AttributeChange, int, error) {
+	client, ok := clientFromEnv()
+	if !ok {
+		return nil, 0, fmt.Errorf("missing iTop API environment variables for history fetch")
+	}
+	quoted := make([]string, len(attributes))
+	for i, a := range attributes {
+		quoted[i] = "'" + a + "'"
+	}
+	params := map[string]interface{}{
+		"class": "CMDBChangeOpSetAttributeScalar",
+		"key": "SELECT CMDBChangeOpSetAttributeScalar WHERE objclass = '" + class + "' AND attcode IN (" + strings.Join(quoted, ",") + ")" +
+			" AND id > " + strconv.Itoa(afterOp),
+		"output_fields": "objkey,attcode,oldvalue,newvalue,date",
+	}
+	body, err := client.Post("core/get", params)
+	if err != nil {
+		return nil, 0, err
+	}
+	var result struct {
+		Objects map[string]struct {
+			Key    json.Number `json:"key"`
+			Fields struct {
+				ObjKey   string `json:"objkey"`
+				AttCode  string `json:"attcode"`
+				OldValue string `json:"oldvalue"`
+				NewValue string `json:"newvalue"`
+				Date     string `json:"date"`
+			} `json:"fields"`
+		} `json:"objects"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, 0, err
+	}
+	changes := make(map[string][]AttributeChange)
+	lastOp := afterOp
+	for _, obj := range result.Objects {
+		opID, _ := strconv.Atoi(obj.Key.String())
+		if opID > lastOp {
+			lastOp = opID
+		}
+		date, err := parseDateFlexible(obj.Fields.Date)
+		if err != nil || date.IsZero() {
+			continue
+		}
+		changes[obj.Fields.ObjKey] = append(changes[obj.Fields.ObjKey], AttributeChange{
+			Attribute: obj.Fields.AttCode,
+			OldValue:  obj.Fields.OldValue,
+			NewValue:  obj.Fields.NewValue,
+			Date:      date,
+			opID:      opID,
+		})
+	}
+	return changes, lastOp, nil
+}
+
+// sortChanges orders changes by date, then by op id for changes made in the same second
+func sortChanges(list []AttributeChange) {
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Date.Equal(list[j].Date) {
+			return list[i].Date.Before(list[j].Date)
+		}
+		return list[i].opID < list[j].opID
+	})
+}
+
+// AttachHistory fetches the history of some attributes of a class and sets it on the tickets
+func AttachHistory(tickets []Ticket, class string, attributes ...string) error {
+	if len(attributes) == 0 {
+		return nil
+	}
+	changes, err := FetchAttributeChanges(class, attributes...)
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool, len(tickets))
+	for i := range tickets {
+		tickets[i].History = changes[tickets[i].ID]
+		keep[tickets[i].ID] = true
+	}
+	forgetHistory(class, keep)
+	return nil
+}
+
+// Changes returns the changes of one attribute, oldest first
+func (t Ticket) Changes(attribute string) []AttributeChange {
+	var list []AttributeChange
+	for _, c := range t.History {
+		if c.Attribute == attribute {
+			list = append(list, c)
+		}
+	}
+	return list
+}
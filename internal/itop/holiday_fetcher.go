@@ -4,30 +4,29 @@ import (
 	"bytes"
 	"crypto/tls"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"os"
 )
 
-// FetchHolidays fetches holiday dates from iTop REST API using env vars ITOP_API_URL, ITOP_API_USER, ITOP_API_PWD
-func FetchHolidays() ([]string, error) {
+// FetchHolidays fetches holidays with their name and HolidayCalendar from iTop REST API using env vars ITOP_API_URL, ITOP_API_USER, ITOP_API_PWD
+func FetchHolidays() ([]Holiday, error) {
 	baseURL := os.Getenv("ITOP_API_URL")
 	username := os.Getenv("ITOP_API_USER")
 	password := os.Getenv("ITOP_API_PWD")
 	if baseURL == "" || username == "" || password == "" {
-		log.Println("Missing iTop API environment variables for holiday fetch")
-		return nil, nil
+		return nil, fmt.Errorf("missing iTop API environment variables for holiday fetch")
 	}
 	payload := map[string]interface{}{
 		"operation":     "core/get",
 		"class":         "Holiday",
 		"key":           "SELECT Holiday",
-		"output_fields": "date",
+		"output_fields": "name,date,calendar_id,calendar_id_friendlyname",
 	}
 	jsonData, _ := json.Marshal(payload)
 	form := map[string]string{
-		"version":   "1.3",
+		"version":   APIVersion,
 		"auth_user": username,
 		"auth_pwd":  password,
 		"json_data": string(jsonData),
@@ -55,19 +54,28 @@ func FetchHolidays() ([]string, error) {
 	}
 	defer resp.Body.Close()
 	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("iTop API response status: %d", resp.StatusCode)
+	}
 	var result struct {
 		Objects map[string]struct {
 			Fields struct {
-				Date string `json:"date"`
+				Name     string `json:"name"`
+				Date     string `json:"date"`
+				Calendar string `json:"calendar_id_friendlyname"`
 			} `json:"fields"`
 		} `json:"objects"`
 	}
 	if err := json.Unmarshal(body, &result); err != nil {
 		return nil, err
 	}
-	var dates []string
+	var holidays []Holiday
 	for _, obj := range result.Objects {
-		dates = append(dates, obj.Fields.Date)
+		holidays = append(holidays, Holiday{
+			Date:     obj.Fields.Date,
+			Name:     obj.Fields.Name,
+			Calendar: obj.Fields.Calendar,
+		})
 	}
-	return dates, nil
+	return holidays, nil
 }
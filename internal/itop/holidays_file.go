@@ -2,23 +2,224 @@ package itop
 
 import (
 	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"itop-sla-exporter/internal/utils"
 )
 
-// LoadHolidaysFromFile reads holidays from a file (one date per line)
+// maxHolidayRangeDays bounds a date range entry (a typo like 2025-01-01..2052-01-01 would otherwise explode)
+const maxHolidayRangeDays = 366
+
+// Holiday is one day off. With WorkingHours set it is a half-day holiday on which only those windows are worked.
+type Holiday struct {
+	Date         string   `json:"date"` // YYYY-MM-DD
+	Name         string   `json:"name,omitempty"`
+	Calendar     string   `json:"calendar,omitempty"`
+	WorkingHours []string `json:"working_hours,omitempty"` // "HH:MM-HH:MM"
+}
+
+// LoadHolidayFile reads a holiday file. Files ending in .yaml/.yml use the YAML format:
+//
+//	calendar: Indonesia
+//	holidays:
+//	  - date: 2025-01-01
+//	    name: New Year's Day
+//	  - from: 2025-12-24
+//	    to: 2025-12-31
+//	    name: Collective leave
+//	  - date: 2025-04-17
+//	    name: Maundy Thursday
+//	    working_hours: ["08:00-12:00"]
+//
+// Files ending in .ics are read as iCalendar (see ParseICS).
+// Other files are read line by line as CSV "date[..end_date][,name[,working hours]]", with "#" comments and a
+// "# calendar: NAME" directive for the following lines; a bare list of YYYY-MM-DD dates is still valid.
+// Invalid entries are skipped and reported together in the returned error, next to the valid holidays.
+func LoadHolidayFile(filePath string) ([]Holiday, error) {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".yaml", ".yml":
+		return loadHolidayYAML(filePath)
+	case ".ics":
+		return LoadHolidayICS(filePath, false)
+	}
+	return loadHolidayText(filePath)
+}
+
+// LoadHolidaysFromFile reads holidays from a file and returns their dates
 func LoadHolidaysFromFile(filePath string) ([]string, error) {
+	holidays, err := LoadHolidayFile(filePath)
+	var dates []string
+	for _, h := range holidays {
+		dates = append(dates, h.Date)
+	}
+	return dates, err
+}
+
+func loadHolidayText(filePath string) ([]Holiday, error) {
 	f, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
-	var holidays []string
+	var holidays []Holiday
+	var problems []error
+	calendar := ""
+	lineNo := 0
 	scanner := bufio.NewScanner(f)
 	for scanner.Scan() {
-		line := scanner.Text()
-		if line != "" {
-			holidays = append(holidays, line)
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			directive := strings.TrimSpace(strings.TrimPrefix(line, "#"))
+			if strings.HasPrefix(strings.ToLower(directive), "calendar:") {
+				calendar = strings.TrimSpace(directive[len("calendar:"):])
+			}
+			continue
+		}
+		r := csv.NewReader(strings.NewReader(line))
+		r.TrimLeadingSpace = true
+		fields, err := r.Read()
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s:%d: %v", filePath, lineNo, err))
+			continue
+		}
+		if len(fields) > 3 {
+			problems = append(problems, fmt.Errorf("%s:%d: too many fields (expected date,name,working hours)", filePath, lineNo))
+			continue
+		}
+		from, to := fields[0], ""
+		if i := strings.Index(from, ".."); i >= 0 {
+			from, to = strings.TrimSpace(from[:i]), strings.TrimSpace(from[i+2:])
+		}
+		entry := Holiday{Calendar: calendar}
+		if len(fields) > 1 {
+			entry.Name = strings.TrimSpace(fields[1])
+		}
+		if len(fields) > 2 {
+			entry.WorkingHours = strings.FieldsFunc(fields[2], func(r rune) bool { return r == ' ' || r == ';' })
+		}
+		expanded, err := expandHoliday(entry, from, to)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s:%d: %v", filePath, lineNo, err))
+			continue
+		}
+		holidays = append(holidays, expanded...)
+	}
+	if err := scanner.Err(); err != nil {
+		return holidays, err
+	}
+	return holidays, errors.Join(problems...)
+}
+
+type holidayYAMLFile struct {
+	Calendar string `yaml:"calendar"`
+	Holidays []struct {
+		Date         string   `yaml:"date"`
+		From         string   `yaml:"from"`
+		To           string   `yaml:"to"`
+		Name         string   `yaml:"name"`
+		Calendar     string   `yaml:"calendar"`
+		WorkingHours []string `yaml:"working_hours"`
+	} `yaml:"holidays"`
+}
+
+func loadHolidayYAML(filePath string) ([]Holiday, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var file holidayYAMLFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %v", filePath, err)
+	}
+	var holidays []Holiday
+	var problems []error
+	for i, h := range file.Holidays {
+		entry := Holiday{Name: h.Name, Calendar: file.Calendar, WorkingHours: h.WorkingHours}
+		if h.Calendar != "" {
+			entry.Calendar = h.Calendar
+		}
+		from, to := h.Date, ""
+		if h.Date == "" {
+			from, to = h.From, h.To
+		} else if h.From != "" || h.To != "" {
+			problems = append(problems, fmt.Errorf("%s: holidays[%d]: use either date or from/to", filePath, i))
+			continue
+		}
+		expanded, err := expandHoliday(entry, from, to)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: holidays[%d]: %v", filePath, i, err))
+			continue
+		}
+		holidays = append(holidays, expanded...)
+	}
+	return holidays, errors.Join(problems...)
+}
+
+// expandHoliday validates an entry and returns one Holiday per day of the from..to range (to may be empty)
+func expandHoliday(entry Holiday, from, to string) ([]Holiday, error) {
+	start, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q (expected YYYY-MM-DD)", from)
+	}
+	end := start
+	if to != "" {
+		if end, err = time.Parse("2006-01-02", to); err != nil {
+			return nil, fmt.Errorf("invalid date %q (expected YYYY-MM-DD)", to)
+		}
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("range %s..%s ends before it starts", from, to)
+	}
+	if end.Sub(start) > maxHolidayRangeDays*24*time.Hour {
+		return nil, fmt.Errorf("range %s..%s is longer than %d days", from, to, maxHolidayRangeDays)
+	}
+	for _, w := range entry.WorkingHours {
+		if _, err := utils.ParseTimeWindow(w); err != nil {
+			return nil, err
+		}
+	}
+	var days []Holiday
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		h := entry
+		h.Date = d.Format("2006-01-02")
+		days = append(days, h)
+	}
+	return days, nil
+}
+
+// HolidaysToSet converts holidays to the set used by business-hour calculations.
+// A date listed both as full and half day is a full day off.
+func HolidaysToSet(holidays []Holiday) utils.Holidays {
+	set := make(utils.Holidays)
+	for _, h := range holidays {
+		if len(h.WorkingHours) == 0 {
+			set[h.Date] = nil
+			continue
+		}
+		if existing, ok := set[h.Date]; ok && existing == nil {
+			continue
+		}
+		var windows []utils.TimeWindow
+		for _, s := range h.WorkingHours {
+			// already validated when loaded
+			if w, err := utils.ParseTimeWindow(s); err == nil {
+				windows = append(windows, w)
+			}
 		}
+		set[h.Date] = windows
 	}
-	return holidays, scanner.Err()
+	return set
 }
@@ -0,0 +1,193 @@
+package itop
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeHolidayFile writes content to name in a temporary directory and returns its path
+func writeHolidayFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadHolidayText(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		want     []Holiday
+		problems []string // substrings of the returned error, one per invalid line
+	}{
+		{
+			name:    "plain legacy dates",
+			content: "2025-01-01\n\n2025-12-25\n",
+			want:    []Holiday{{Date: "2025-01-01"}, {Date: "2025-12-25"}},
+		},
+		{
+			name:    "name and comments",
+			content: "# public holidays\n2025-01-01, New Year's Day\n",
+			want:    []Holiday{{Date: "2025-01-01", Name: "New Year's Day"}},
+		},
+		{
+			name:    "quoted name with a comma",
+			content: `2025-12-26,"Boxing Day, observed"` + "\n",
+			want:    []Holiday{{Date: "2025-12-26", Name: "Boxing Day, observed"}},
+		},
+		{
+			name:    "range",
+			content: "2025-12-30..2026-01-01,Collective leave\n",
+			want: []Holiday{
+				{Date: "2025-12-30", Name: "Collective leave"},
+				{Date: "2025-12-31", Name: "Collective leave"},
+				{Date: "2026-01-01", Name: "Collective leave"},
+			},
+		},
+		{
+			name:    "half day",
+			content: "2025-12-24,Christmas Eve,08:00-12:00;13:00-15:00\n",
+			want:    []Holiday{{Date: "2025-12-24", Name: "Christmas Eve", WorkingHours: []string{"08:00-12:00", "13:00-15:00"}}},
+		},
+		{
+			name:    "calendar directive",
+			content: "2025-01-01\n# calendar: Indonesia\n2025-03-31,Idul Fitri\n#Calendar: Singapore\n2025-08-09,National Day\n",
+			want: []Holiday{
+				{Date: "2025-01-01"},
+				{Date: "2025-03-31", Name: "Idul Fitri", Calendar: "Indonesia"},
+				{Date: "2025-08-09", Name: "National Day", Calendar: "Singapore"},
+			},
+		},
+		{
+			name:     "invalid lines reported, valid ones kept",
+			content:  "2025-13-01\n2025-01-01\n2025-02-01,a,08:00-12:00,extra\n2025-03-02..2025-03-01\n2025-05-01,Labour Day,25:00-26:00\n2025-01-01..2027-01-01\n",
+			want:     []Holiday{{Date: "2025-01-01"}},
+			problems: []string{":1: invalid date", ":3: too many fields", ":4: range", ":5:", ":6: range"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadHolidayFile(writeHolidayFile(t, "holidays.txt", tt.content))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("holidays = %+v, want %+v", got, tt.want)
+			}
+			checkProblems(t, err, tt.problems)
+		})
+	}
+}
+
+func TestLoadHolidayYAML(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		want     []Holiday
+		problems []string
+	}{
+		{
+			name: "dates, ranges and half days",
+			content: `calendar: Indonesia
+holidays:
+  - date: 2025-01-01
+    name: New Year's Day
+  - from: 2025-12-30
+    to: 2025-12-31
+    name: Collective leave
+  - date: 2025-04-17
+    name: Maundy Thursday
+    working_hours: ["08:00-12:00"]
+  - date: 2025-08-09
+    name: National Day
+    calendar: Singapore
+`,
+			want: []Holiday{
+				{Date: "2025-01-01", Name: "New Year's Day", Calendar: "Indonesia"},
+				{Date: "2025-12-30", Name: "Collective leave", Calendar: "Indonesia"},
+				{Date: "2025-12-31", Name: "Collective leave", Calendar: "Indonesia"},
+				{Date: "2025-04-17", Name: "Maundy Thursday", Calendar: "Indonesia", WorkingHours: []string{"08:00-12:00"}},
+				{Date: "2025-08-09", Name: "National Day", Calendar: "Singapore"},
+			},
+		},
+		{
+			name: "invalid entries reported, valid ones kept",
+			content: `holidays:
+  - date: 2025-01-01
+    from: 2025-01-02
+  - date: 2025-02-30
+  - date: 2025-05-01
+    working_hours: ["noon"]
+  - date: 2025-12-25
+`,
+			want:     []Holiday{{Date: "2025-12-25"}},
+			problems: []string{"holidays[0]: use either date or from/to", "holidays[1]: invalid date", "holidays[2]:"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadHolidayFile(writeHolidayFile(t, "holidays.yaml", tt.content))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("holidays = %+v, want %+v", got, tt.want)
+			}
+			checkProblems(t, err, tt.problems)
+		})
+	}
+}
+
+func TestLoadHolidayYAMLUnknownField(t *testing.T) {
+	path := writeHolidayFile(t, "holidays.yml", "holidays:\n  - date: 2025-01-01\n    nmae: typo\n")
+	if got, err := LoadHolidayFile(path); err == nil || got != nil {
+		t.Errorf("LoadHolidayFile = %+v, %v; want the whole file rejected", got, err)
+	}
+}
+
+// TestFormatHolidaysRoundTrip checks that the file the iTop sync writes reads back as the same holidays
+func TestFormatHolidaysRoundTrip(t *testing.T) {
+	list := dedupeHolidays([]Holiday{
+		{Date: "2025-01-01", Name: "New Year's Day", Calendar: "Indonesia"},
+		{Date: "2025-03-31", Name: "Idul Fitri, day 1", Calendar: "Indonesia"},
+		{Date: "2025-08-09", Name: `National "Day"`, Calendar: "Singapore"},
+		{Date: "2025-12-25", Calendar: "Singapore"},
+		{Date: "2025-12-26", Name: "Boxing Day"},
+	})
+	got, err := LoadHolidayFile(writeHolidayFile(t, "holidays.txt", string(formatHolidays(list))))
+	if err != nil {
+		t.Fatalf("LoadHolidayFile: %v", err)
+	}
+	if !reflect.DeepEqual(got, list) {
+		t.Errorf("round trip = %+v, want %+v", got, list)
+	}
+}
+
+func TestFormatHolidaysEmpty(t *testing.T) {
+	got, err := LoadHolidayFile(writeHolidayFile(t, "holidays.txt", string(formatHolidays(nil))))
+	if err != nil || len(got) != 0 {
+		t.Errorf("empty round trip = %+v, %v", got, err)
+	}
+}
+
+// checkProblems checks that err reports exactly the expected problems, in order
+func checkProblems(t *testing.T, err error, want []string) {
+	t.Helper()
+	if len(want) == 0 {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil {
+		t.Fatalf("no error, want %d problems", len(want))
+	}
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("%d problems, want %d:\n%v", len(lines), len(want), err)
+	}
+	for i, w := range want {
+		if !strings.Contains(lines[i], w) {
+			t.Errorf("problem %d = %q, want it to contain %q", i, lines[i], w)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package itop
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LoadHolidayICS reads holidays from an iCalendar (.ics) file path or http(s) URL.
+// Each VEVENT becomes a full-day holiday for every date it covers; its SUMMARY is the holiday name.
+// HTTPS certificates are verified unless skipVerify is set: a forged feed could pause SLA clocks.
+func LoadHolidayICS(source string, skipVerify bool) ([]Holiday, error) {
+	var r io.Reader
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		client := &http.Client{Timeout: 30 * time.Second}
+		if skipVerify {
+			client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+		}
+		resp, err := client.Get(source)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s: HTTP status %d", source, resp.StatusCode)
+		}
+		r = resp.Body
+	} else {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	holidays, err := ParseICS(r)
+	if err != nil {
+		return holidays, fmt.Errorf("%s: %w", source, err)
+	}
+	return holidays, nil
+}
+
+// ParseICS parses the VEVENTs of an iCalendar stream. The calendar name is taken from X-WR-CALNAME.
+// Invalid events are skipped and reported together in the returned error.
+func ParseICS(r io.Reader) ([]Holiday, error) {
+	lines, err := unfoldICS(r)
+	if err != nil {
+		return nil, err
+	}
+	var holidays []Holiday
+	var problems []error
+	calendar := ""
+	var event map[string]icsProperty
+	for _, line := range lines {
+		p := parseICSProperty(line)
+		switch {
+		case p.name == "BEGIN" && p.value == "VEVENT":
+			event = make(map[string]icsProperty)
+		case p.name == "END" && p.value == "VEVENT":
+			list, err := icsEventHolidays(event, calendar)
+			if err != nil {
+				problems = append(problems, err)
+			}
+			holidays = append(holidays, list...)
+			event = nil
+		case event != nil:
+			event[p.name] = p
+		case p.name == "X-WR-CALNAME":
+			calendar = unescapeICS(p.value)
+		}
+	}
+	for i := range holidays {
+		if holidays[i].Calendar == "" {
+			holidays[i].Calendar = calendar
+		}
+	}
+	return holidays, errors.Join(problems...)
+}
+
+type icsProperty struct {
+	name   string
+	params string
+	value  string
+}
+
+// parseICSProperty splits "NAME;PARAMS:VALUE"
+func parseICSProperty(line string) icsProperty {
+	var p icsProperty
+	colon := strings.Index(line, ":")
+	if colon < 0 {
+		return p
+	}
+	head := line[:colon]
+	p.value = strings.TrimSpace(line[colon+1:])
+	if semi := strings.Index(head, ";"); semi >= 0 {
+		p.name, p.params = strings.ToUpper(head[:semi]), head[semi+1:]
+	} else {
+		p.name = strings.ToUpper(head)
+	}
+	return p
+}
+
+// unfoldICS joins continuation lines (starting with a space or tab) to the previous line
+func unfoldICS(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// icsEventHolidays converts one VEVENT to holidays; DTEND is exclusive for all-day events
+func icsEventHolidays(event map[string]icsProperty, calendar string) ([]Holiday, error) {
+	name := unescapeICS(event["SUMMARY"].value)
+	start, err := parseICSDate(event["DTSTART"].value)
+	if err != nil {
+		return nil, fmt.Errorf("event %q: DTSTART: %v", name, err)
+	}
+	end := start
+	if dtend, ok := event["DTEND"]; ok {
+		e, err := parseICSDate(dtend.value)
+		if err != nil {
+			return nil, fmt.Errorf("event %q: DTEND: %v", name, err)
+		}
+		allDay := len(dtend.value) == len("20060102")
+		if allDay || e.Format("150405") == "000000" {
+			e = e.AddDate(0, 0, -1)
+		}
+		if !e.Before(start) {
+			end = e
+		}
+	}
+	if _, ok := event["RRULE"]; ok {
+		return nil, fmt.Errorf("event %q: recurring events (RRULE) are not supported, list each occurrence", name)
+	}
+	to := ""
+	if !end.Equal(start) {
+		to = end.Format("2006-01-02")
+	}
+	list, err := expandHoliday(Holiday{Name: name, Calendar: calendar}, start.Format("2006-01-02"), to)
+	if err != nil {
+		return nil, fmt.Errorf("event %q: %v", name, err)
+	}
+	return list, nil
+}
+
+// parseICSDate parses a DATE (20250101) or DATE-TIME (20250101T090000[Z]) value; the date is kept as written
+func parseICSDate(v string) (time.Time, error) {
+	v = strings.TrimSuffix(v, "Z")
+	for _, layout := range []string{"20060102", "20060102T150405"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", v)
+}
+
+var icsUnescaper = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+var icsEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, ",", `\,`, ";", `\;`)
+
+func unescapeICS(s string) string {
+	return icsUnescaper.Replace(s)
+}
+
+// FormatICS renders holidays as an iCalendar feed with one all-day event per date
+func FormatICS(calendarName string, holidays []Holiday) []byte {
+	byDate := make(map[string]Holiday)
+	for _, h := range holidays {
+		if existing, ok := byDate[h.Date]; ok && len(existing.WorkingHours) == 0 {
+			continue // a full day off wins over a half day
+		}
+		byDate[h.Date] = h
+	}
+	dates := make([]string, 0, len(byDate))
+	for d := range byDate {
+		dates = append(dates, d)
+	}
+	sort.Strings(dates)
+
+	var buf bytes.Buffer
+	write := func(line string) {
+		buf.WriteString(foldICS(line))
+		buf.WriteString("\r\n")
+	}
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	write("BEGIN:VCALENDAR")
+	write("VERSION:2.0")
+	write("PRODID:-//itop-sla-exporter//holidays//EN")
+	write("CALSCALE:GREGORIAN")
+	write("X-WR-CALNAME:" + icsEscaper.Replace(calendarName))
+	for _, d := range dates {
+		h := byDate[d]
+		day, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			continue
+		}
+		name := h.Name
+		if name == "" {
+			name = "Holiday"
+		}
+		write("BEGIN:VEVENT")
+		write("UID:" + day.Format("20060102") + "-" + strings.ReplaceAll(strings.ToLower(calendarName), " ", "-") + "@itop-sla-exporter")
+		write("DTSTAMP:" + stamp)
+		write("DTSTART;VALUE=DATE:" + day.Format("20060102"))
+		write("DTEND;VALUE=DATE:" + day.AddDate(0, 0, 1).Format("20060102"))
+		write("SUMMARY:" + icsEscaper.Replace(name))
+		if len(h.WorkingHours) > 0 {
+			write("DESCRIPTION:" + icsEscaper.Replace("Half day, working hours "+strings.Join(h.WorkingHours, " ")))
+		}
+		write("TRANSP:TRANSPARENT")
+		write("END:VEVENT")
+	}
+	write("END:VCALENDAR")
+	return buf.Bytes()
+}
+
+// foldICS splits a content line into 75-octet chunks as required by RFC 5545, without cutting UTF-8 sequences
+func foldICS(line string) string {
+	if len(line) <= 75 {
+		return line
+	}
+	var b strings.Builder
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74 // continuation lines start with a space
+	}
+	b.WriteString(line)
+	return b.String()
+}
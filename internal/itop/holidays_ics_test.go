@@ -0,0 +1,113 @@
+package itop
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ics wraps CRLF-terminated lines in a VCALENDAR
+func ics(lines ...string) string {
+	all := append(append([]string{"BEGIN:VCALENDAR", "VERSION:2.0"}, lines...), "END:VCALENDAR")
+	return strings.Join(all, "\r\n") + "\r\n"
+}
+
+func TestParseICS(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		want     []Holiday
+		problems []string
+	}{
+		{
+			name: "all-day event, DTEND exclusive",
+			content: ics("X-WR-CALNAME:Indonesia",
+				"BEGIN:VEVENT", "DTSTART;VALUE=DATE:20250101", "DTEND;VALUE=DATE:20250102", "SUMMARY:New Year's Day", "END:VEVENT"),
+			want: []Holiday{{Date: "2025-01-01", Name: "New Year's Day", Calendar: "Indonesia"}},
+		},
+		{
+			name:    "multi-day all-day event",
+			content: ics("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20251230", "DTEND;VALUE=DATE:20260101", "SUMMARY:Collective leave", "END:VEVENT"),
+			want:    []Holiday{{Date: "2025-12-30", Name: "Collective leave"}, {Date: "2025-12-31", Name: "Collective leave"}},
+		},
+		{
+			name:    "no DTEND",
+			content: ics("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20250501", "SUMMARY:Labour Day", "END:VEVENT"),
+			want:    []Holiday{{Date: "2025-05-01", Name: "Labour Day"}},
+		},
+		{
+			name:    "date-time ending at midnight",
+			content: ics("BEGIN:VEVENT", "DTSTART:20250817T000000", "DTEND:20250818T000000", "SUMMARY:Independence Day", "END:VEVENT"),
+			want:    []Holiday{{Date: "2025-08-17", Name: "Independence Day"}},
+		},
+		{
+			name:    "date-time ending during the day",
+			content: ics("BEGIN:VEVENT", "DTSTART:20250817T080000Z", "DTEND:20250818T120000Z", "SUMMARY:Event", "END:VEVENT"),
+			want:    []Holiday{{Date: "2025-08-17", Name: "Event"}, {Date: "2025-08-18", Name: "Event"}},
+		},
+		{
+			name: "folded lines",
+			content: ics("X-WR-CALNAME:Singa", " pore",
+				"BEGIN:VEVENT", "DTSTART;VALUE=DATE:20250809", "SUMMARY:National", "\t Day", "END:VEVENT"),
+			want: []Holiday{{Date: "2025-08-09", Name: "National Day", Calendar: "Singapore"}},
+		},
+		{
+			name: "escaping",
+			content: ics(`X-WR-CALNAME:HR\, Jakarta`,
+				"BEGIN:VEVENT", "DTSTART;VALUE=DATE:20251225", `SUMMARY:Christmas\; Boxing\, \\o/`, "END:VEVENT"),
+			want: []Holiday{{Date: "2025-12-25", Name: `Christmas; Boxing, \o/`, Calendar: "HR, Jakarta"}},
+		},
+		{
+			name:    "LF line endings",
+			content: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;VALUE=DATE:20250101\nSUMMARY:New Year\nEND:VEVENT\nEND:VCALENDAR\n",
+			want:    []Holiday{{Date: "2025-01-01", Name: "New Year"}},
+		},
+		{
+			name: "RRULE rejected, other events kept",
+			content: ics(
+				"BEGIN:VEVENT", "DTSTART;VALUE=DATE:20250101", "RRULE:FREQ=YEARLY", "SUMMARY:New Year", "END:VEVENT",
+				"BEGIN:VEVENT", "DTSTART;VALUE=DATE:20251225", "SUMMARY:Christmas", "END:VEVENT"),
+			want:     []Holiday{{Date: "2025-12-25", Name: "Christmas"}},
+			problems: []string{`"New Year": recurring events (RRULE)`},
+		},
+		{
+			name: "invalid dates reported",
+			content: ics(
+				"BEGIN:VEVENT", "DTSTART:2025-01-01", "SUMMARY:Bad start", "END:VEVENT",
+				"BEGIN:VEVENT", "DTSTART;VALUE=DATE:20250101", "DTEND;VALUE=DATE:2025010", "SUMMARY:Bad end", "END:VEVENT"),
+			problems: []string{`"Bad start": DTSTART`, `"Bad end": DTEND`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseICS(strings.NewReader(tt.content))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("holidays = %+v, want %+v", got, tt.want)
+			}
+			checkProblems(t, err, tt.problems)
+		})
+	}
+}
+
+// TestFormatICSRoundTrip checks that the /holidays.ics feed parses back to the same days, half days as full
+func TestFormatICSRoundTrip(t *testing.T) {
+	list := []Holiday{
+		{Date: "2025-01-01", Name: "New Year's Day"},
+		{Date: "2025-12-24", Name: "Christmas Eve", WorkingHours: []string{"08:00-12:00"}},
+		{Date: "2025-12-26", Name: "Boxing Day, observed; " + strings.Repeat("long name ", 10) + "end"},
+		{Date: "2025-12-31"},
+	}
+	got, err := ParseICS(strings.NewReader(string(FormatICS("HR, Jakarta", list))))
+	if err != nil {
+		t.Fatalf("ParseICS: %v", err)
+	}
+	want := []Holiday{
+		{Date: "2025-01-01", Name: "New Year's Day", Calendar: "HR, Jakarta"},
+		{Date: "2025-12-24", Name: "Christmas Eve", Calendar: "HR, Jakarta"},
+		{Date: "2025-12-26", Name: list[2].Name, Calendar: "HR, Jakarta"},
+		{Date: "2025-12-31", Name: "Holiday", Calendar: "HR, Jakarta"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+}
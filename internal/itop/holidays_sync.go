@@ -1,9 +1,14 @@
 package itop
 
 import (
-	"io/ioutil"
+	"bytes"
+	"encoding/csv"
+	"fmt"
 	"log"
-	"strings"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
 	"time"
 )
 
@@ -15,17 +20,42 @@ type holidayResp struct {
 	} `json:"objects"`
 }
 
-// SyncHolidaysToFile periodically fetches holidays from iTop and writes to file (using env vars)
-func SyncHolidaysToFile(filePath string, interval time.Duration) {
+// HolidaySyncStatus reports the outcome of the holiday sync
+type HolidaySyncStatus struct {
+	LastSuccess time.Time // last time the file was written (or already up to date)
+	Holidays    int       // holidays in the file after the last success
+	Errors      int       // failed syncs since start
+}
+
+var (
+	syncStatus   HolidaySyncStatus
+	syncStatusMu sync.RWMutex
+)
+
+// GetHolidaySyncStatus returns the current holiday sync status
+func GetHolidaySyncStatus() HolidaySyncStatus {
+	syncStatusMu.RLock()
+	defer syncStatusMu.RUnlock()
+	return syncStatus
+}
+
+// SyncHolidaysToFile periodically fetches holidays from iTop and writes to file (using env vars).
+// onChange, if set, is called after the file has been rewritten with different holidays.
+func SyncHolidaysToFile(filePath string, interval time.Duration, onChange func()) {
 	go func() {
 		for {
-			list, err := FetchHolidays()
+			n, changed, err := syncHolidaysOnce(filePath)
+			syncStatusMu.Lock()
 			if err != nil {
-				log.Printf("Failed to fetch holidays: %v", err)
+				log.Printf("Failed to sync holidays: %v", err)
+				syncStatus.Errors++
 			} else {
-				if err := ioutil.WriteFile(filePath, []byte(joinLines(list)), 0644); err != nil {
-					log.Printf("Failed to write holidays.txt: %v", err)
-				}
+				syncStatus.LastSuccess = time.Now()
+				syncStatus.Holidays = n
+			}
+			syncStatusMu.Unlock()
+			if changed && onChange != nil {
+				onChange()
 			}
 			time.Sleep(interval)
 		}
@@ -34,6 +64,94 @@ func SyncHolidaysToFile(filePath string, interval time.Duration) {
 
 // fetchHolidays removed: use FetchHolidays from holiday_fetcher.go
 
-func joinLines(list []string) string {
-	return strings.Join(list, "\n") + "\n"
+// syncHolidaysOnce fetches the holidays and atomically replaces the file, reporting whether its content changed.
+// An empty result never replaces a file that still lists holidays (last-known-good), since it usually means a failed fetch.
+func syncHolidaysOnce(filePath string) (int, bool, error) {
+	list, err := FetchHolidays()
+	if err != nil {
+		return 0, false, err
+	}
+	list = dedupeHolidays(list)
+	if len(list) == 0 {
+		if existing, _ := LoadHolidayFile(filePath); len(existing) > 0 {
+			return 0, false, fmt.Errorf("iTop returned no holidays, keeping the %d holidays of %s", len(existing), filePath)
+		}
+	}
+	data := formatHolidays(list)
+	if current, err := os.ReadFile(filePath); err == nil && bytes.Equal(current, data) {
+		return len(list), false, nil
+	}
+	if err := writeFileAtomic(filePath, data, 0644); err != nil {
+		return 0, false, fmt.Errorf("failed to write %s: %v", filePath, err)
+	}
+	return len(list), true, nil
+}
+
+// dedupeHolidays sorts holidays by calendar, date and name and drops repeated calendar/date pairs
+func dedupeHolidays(list []Holiday) []Holiday {
+	sorted := append([]Holiday{}, list...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Calendar != sorted[j].Calendar {
+			return sorted[i].Calendar < sorted[j].Calendar
+		}
+		if sorted[i].Date != sorted[j].Date {
+			return sorted[i].Date < sorted[j].Date
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	var out []Holiday
+	for _, h := range sorted {
+		if n := len(out); n > 0 && out[n-1].Calendar == h.Calendar && out[n-1].Date == h.Date {
+			continue
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it over path,
+// so that readers never see a partially written file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// formatHolidays writes holidays (sorted by calendar) in the text format read by LoadHolidayFile,
+// one "# calendar:" section per iTop HolidayCalendar
+func formatHolidays(list []Holiday) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	calendar := ""
+	for _, h := range list {
+		if h.Calendar != calendar {
+			w.Flush()
+			buf.WriteString("# calendar: " + h.Calendar + "\n")
+			calendar = h.Calendar
+		}
+		record := []string{h.Date}
+		if h.Name != "" {
+			record = append(record, h.Name)
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	return buf.Bytes()
 }
@@ -0,0 +1,116 @@
+package itop
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FetchEnumLabels returns the display names iTop shows for enum codes, by attribute and code. The REST API
+// only returns codes, so the names are read from a localized export-v2.php export of one sample ticket per
+// code (samples: attribute, then code, then the id of a ticket with that code): a single export of at most
+// one row per code, whatever the number of tickets.
+func FetchEnumLabels(class string, samples map[string]map[string]string) (map[string]map[string]string, error) {
+	client, ok := clientFromEnv()
+	if !ok {
+		return nil, fmt.Errorf("missing iTop API environment variables for label fetch")
+	}
+	var attributes []string
+	idSet := make(map[string]bool)
+	for attr, codes := range samples {
+		attributes = append(attributes, attr)
+		for _, id := range codes {
+			if _, err := strconv.Atoi(id); err == nil {
+				idSet[id] = true
+			}
+		}
+	}
+	if len(idSet) == 0 {
+		return nil, nil
+	}
+	sort.Strings(attributes)
+	ids := make([]string, 0, len(idSet))
+	for id := range idSet {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	oql := "SELECT " + class + " WHERE id IN (" + strings.Join(ids, ",") + ")"
+	rows, err := client.exportCSV(oql, "id,"+strings.Join(attributes, ","))
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string][]string, len(rows))
+	for _, row := range rows {
+		if len(row) == len(attributes)+1 {
+			byID[row[0]] = row[1:]
+		}
+	}
+	labels := make(map[string]map[string]string)
+	for i, attr := range attributes {
+		for code, id := range samples[attr] {
+			row, ok := byID[id]
+			if !ok || row[i] == "" || row[i] == code {
+				continue
+			}
+			if labels[attr] == nil {
+				labels[attr] = make(map[string]string)
+			}
+			labels[attr][code] = row[i]
+		}
+	}
+	return labels, nil
+}
+
+// exportCSV runs an OQL query through export-v2.php and returns the localized CSV rows without the header
+func (c *ITopClient) exportCSV(oql, fields string) ([][]string, error) {
+	form := url.Values{}
+	form.Set("auth_user", c.Username)
+	form.Set("auth_pwd", c.Password)
+	form.Set("expression", oql)
+	form.Set("fields", fields)
+	form.Set("format", "csv")
+	form.Set("charset", "UTF-8")
+	req, err := http.NewRequest("POST", exportURL(c.BaseURL), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("iTop export response status: %d", resp.StatusCode)
+	}
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))))
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("iTop export: %v", err)
+	}
+	if len(rows) > 0 {
+		rows = rows[1:]
+	}
+	return rows, nil
+}
+
+// exportURL derives the export-v2.php URL from the REST URL (.../webservices/rest.php)
+func exportURL(restURL string) string {
+	if i := strings.LastIndex(restURL, "/rest.php"); i >= 0 {
+		return restURL[:i] + "/export-v2.php"
+	}
+	return strings.TrimSuffix(restURL, "/") + "/webservices/export-v2.php"
+}
@@ -18,10 +18,14 @@ type Ticket struct {
 	StartDate          time.Time
 	AssignmentDate     time.Time
 	ResolutionDate     time.Time
+	CloseDate          time.Time
 	TTODeadline        time.Time
 	TTRDeadline        time.Time
 	SLATTOPassed       string
 	SLATTRPassed       string
+	TTOEscalation      time.Time // tto_escalation_deadline (75% of the TTO), only fetched for the iTop SLA engine
+	TTREscalation      time.Time // ttr_escalation_deadline (75% of the TTR), only fetched for the iTop SLA engine
+	FirstAgentLog      time.Time // oldest public_log entry not written by the caller (only fetched when needed)
 	Agent              string
 	Team               string
 	Priority           string
@@ -30,7 +34,9 @@ type Ticket struct {
 	ServiceID          string
 	AgentID            string
 	TeamID             string
-	TicketType         string // for future multi-class
-	Caller             string // caller_id_friendlyname
-	Origin             string // origin
+	TicketType         string            // for future multi-class
+	Caller             string            // caller_id_friendlyname
+	Organization       string            // org_id_friendlyname
+	Origin             string            // origin
+	History            []AttributeChange // changes of the tracked attributes, oldest first (only fetched when needed)
 }
@@ -2,6 +2,8 @@ package itop
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -30,31 +32,38 @@ func parseDateFlexible(s string) (time.Time, error) {
 }
 
 type TicketResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
 	Objects map[string]struct {
 		Fields struct {
-			ID                     string `json:"id"`
-			Ref                    string `json:"ref"`
-			Title                  string `json:"title"`
-			Status                 string `json:"status"`
-			Priority               string `json:"priority"`
-			Urgency                string `json:"urgency"`
-			Impact                 string `json:"impact"`
-			ServiceID              string `json:"service_id"`
-			ServiceName            string `json:"service_name"`
-			ServiceSubcategoryName string `json:"servicesubcategory_name"`
-			AgentID                string `json:"agent_id"`
-			Agent                  string `json:"agent_id_friendlyname"`
-			TeamID                 string `json:"team_id"`
-			Team                   string `json:"team_id_friendlyname"`
-			Caller                 string `json:"caller_id_friendlyname"`
-			Origin                 string `json:"origin"`
-			StartDate              string `json:"start_date"`
-			AssignmentDate         string `json:"assignment_date"`
-			ResolutionDate         string `json:"resolution_date"`
-			TTODeadline            string `json:"tto_deadline"`
-			TTRDeadline            string `json:"ttr_deadline"`
-			SLATTOPassed           string `json:"sla_tto_passed"`
-			SLATTRPassed           string `json:"sla_ttr_passed"`
+			ID                     string          `json:"id"`
+			Ref                    string          `json:"ref"`
+			Title                  string          `json:"title"`
+			Status                 string          `json:"status"`
+			Priority               string          `json:"priority"`
+			Urgency                string          `json:"urgency"`
+			Impact                 string          `json:"impact"`
+			ServiceID              string          `json:"service_id"`
+			ServiceName            string          `json:"service_name"`
+			ServiceSubcategoryName string          `json:"servicesubcategory_name"`
+			AgentID                string          `json:"agent_id"`
+			Agent                  string          `json:"agent_id_friendlyname"`
+			TeamID                 string          `json:"team_id"`
+			Team                   string          `json:"team_id_friendlyname"`
+			Caller                 string          `json:"caller_id_friendlyname"`
+			Organization           string          `json:"org_id_friendlyname"`
+			Origin                 string          `json:"origin"`
+			StartDate              string          `json:"start_date"`
+			AssignmentDate         string          `json:"assignment_date"`
+			ResolutionDate         string          `json:"resolution_date"`
+			CloseDate              string          `json:"close_date"`
+			TTODeadline            string          `json:"tto_deadline"`
+			TTRDeadline            string          `json:"ttr_deadline"`
+			SLATTOPassed           string          `json:"sla_tto_passed"`
+			SLATTRPassed           string          `json:"sla_ttr_passed"`
+			TTOEscalationDeadline  string          `json:"tto_escalation_deadline"`
+			TTREscalationDeadline  string          `json:"ttr_escalation_deadline"`
+			PublicLog              json.RawMessage `json:"public_log"`
 		} `json:"fields"`
 	} `json:"objects"`
 }
@@ -64,14 +73,21 @@ func ParseTickets(data []byte) ([]Ticket, error) {
 	if err := json.Unmarshal(data, &resp); err != nil {
 		return nil, err
 	}
+	// iTop answers errors (e.g. an unknown output_fields attribute) with a non-zero code and no objects
+	if resp.Code != 0 {
+		return nil, fmt.Errorf("iTop API error %d: %s", resp.Code, resp.Message)
+	}
 	var tickets []Ticket
 	for _, obj := range resp.Objects {
 		fields := obj.Fields
 		startDate, _ := parseDateFlexible(fields.StartDate)
 		assignmentDate, _ := parseDateFlexible(fields.AssignmentDate)
 		resolutionDate, _ := parseDateFlexible(fields.ResolutionDate)
+		closeDate, _ := parseDateFlexible(fields.CloseDate)
 		ttoDeadline, _ := parseDateFlexible(fields.TTODeadline)
 		ttrDeadline, _ := parseDateFlexible(fields.TTRDeadline)
+		ttoEscalation, _ := parseDateFlexible(fields.TTOEscalationDeadline)
+		ttrEscalation, _ := parseDateFlexible(fields.TTREscalationDeadline)
 
 		ticket := Ticket{
 			ID:                 fields.ID,
@@ -84,10 +100,13 @@ func ParseTickets(data []byte) ([]Ticket, error) {
 			StartDate:          startDate,
 			AssignmentDate:     assignmentDate,
 			ResolutionDate:     resolutionDate,
+			CloseDate:          closeDate,
 			TTODeadline:        ttoDeadline,
 			TTRDeadline:        ttrDeadline,
 			SLATTOPassed:       fields.SLATTOPassed,
 			SLATTRPassed:       fields.SLATTRPassed,
+			TTOEscalation:      ttoEscalation,
+			TTREscalation:      ttrEscalation,
 			Agent:              fields.Agent,
 			AgentID:            fields.AgentID,
 			Team:               fields.Team,
@@ -97,7 +116,9 @@ func ParseTickets(data []byte) ([]Ticket, error) {
 			Impact:             fields.Impact,
 			ServiceID:          fields.ServiceID,
 			Caller:             fields.Caller,
+			Organization:       fields.Organization,
 			Origin:             fields.Origin,
+			FirstAgentLog:      firstAgentLogEntry(fields.PublicLog, fields.Caller),
 		}
 		// Calculate TTO/TTR
 		if !assignmentDate.IsZero() && !startDate.IsZero() {
@@ -110,3 +131,33 @@ func ParseTickets(data []byte) ([]Ticket, error) {
 	}
 	return tickets, nil
 }
+
+// firstAgentLogEntry returns the date of the oldest caselog entry not written by the caller (zero if none or not fetched)
+func firstAgentLogEntry(caselog json.RawMessage, caller string) time.Time {
+	if len(caselog) == 0 {
+		return time.Time{}
+	}
+	var log struct {
+		Entries []struct {
+			Date      string `json:"date"`
+			UserLogin string `json:"user_login"`
+		} `json:"entries"`
+	}
+	if err := json.Unmarshal(caselog, &log); err != nil {
+		return time.Time{}
+	}
+	var first time.Time
+	for _, e := range log.Entries {
+		if e.UserLogin == "" || strings.EqualFold(strings.TrimSpace(e.UserLogin), strings.TrimSpace(caller)) {
+			continue
+		}
+		date, err := parseDateFlexible(e.Date)
+		if err != nil || date.IsZero() {
+			continue
+		}
+		if first.IsZero() || date.Before(first) {
+			first = date
+		}
+	}
+	return first
+}
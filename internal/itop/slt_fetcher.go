@@ -58,7 +58,7 @@ func GetTicketSLT(class, ref, priority, serviceName string) (SLTDeadline, error)
 	}
 	jsonData1, _ := json.Marshal(payload1)
 	form1 := map[string]string{
-		"version":   "1.3",
+		"version":   APIVersion,
 		"auth_user": username,
 		"auth_pwd":  password,
 		"json_data": string(jsonData1),
@@ -113,7 +113,7 @@ func GetTicketSLT(class, ref, priority, serviceName string) (SLTDeadline, error)
 	}
 	jsonData2, _ := json.Marshal(payload2)
 	form2 := map[string]string{
-		"version":   "1.3",
+		"version":   APIVersion,
 		"auth_user": username,
 		"auth_pwd":  password,
 		"json_data": string(jsonData2),
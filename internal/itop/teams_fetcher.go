@@ -0,0 +1,40 @@
+package itop
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// FetchTeamNames returns the names of all teams by id. The history only records team_id values,
+// so hand-offs to or from teams no current ticket belongs to need this lookup.
+func FetchTeamNames() (map[string]string, error) {
+	client, ok := clientFromEnv()
+	if !ok {
+		return nil, fmt.Errorf("missing iTop API environment variables for team fetch")
+	}
+	params := map[string]interface{}{
+		"class":         "Team",
+		"key":           "SELECT Team",
+		"output_fields": "name",
+	}
+	body, err := client.Post("core/get", params)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Objects map[string]struct {
+			Key    json.Number `json:"key"`
+			Fields struct {
+				Name string `json:"name"`
+			} `json:"fields"`
+		} `json:"objects"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(result.Objects))
+	for _, obj := range result.Objects {
+		names[obj.Key.String()] = obj.Fields.Name
+	}
+	return names, nil
+}
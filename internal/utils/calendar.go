@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"sort"
+	"time"
+)
+
+// BusinessCalendar answers business-hour queries for one schedule and holiday set without walking day by day.
+// It is built once for a range of days, with the cumulative working time before each day precomputed,
+// and is safe for concurrent use. Queries outside the range fall back to Schedule.Duration.
+type BusinessCalendar struct {
+	schedule *Schedule
+	holidays Holidays
+	loc      *time.Location
+	firstDay int64       // civil day number (days since 1970-01-01) of days[0]
+	days     [][]segment // working segments of each day, overnight windows already split at midnight
+	cum      []int64     // cum[i] is the working time (ns) before days[i]; len(cum) == len(days)+1
+}
+
+// segment is a working interval in Unix nanoseconds
+type segment struct {
+	start int64
+	end   int64
+}
+
+// NewBusinessCalendar precomputes the working segments of every day between from and to (inclusive) in loc
+func NewBusinessCalendar(s *Schedule, holidays Holidays, loc *time.Location, from, to time.Time) *BusinessCalendar {
+	c := &BusinessCalendar{schedule: s, holidays: holidays, loc: loc}
+	from, to = from.In(loc), to.In(loc)
+	c.firstDay = civilDay(from)
+	n := int(civilDay(to)-c.firstDay) + 1
+	if n < 1 || s.AlwaysOn {
+		return c
+	}
+	c.days = make([][]segment, n)
+	// begin one day early so that an overnight window spills into days[0]
+	day := time.Date(from.Year(), from.Month(), from.Day()-1, 0, 0, 0, 0, loc)
+	for i := -1; i < n; i++ {
+		next := time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, loc)
+		for _, w := range s.windowsOn(day, holidays) {
+			ws, we := windowTime(day, w.Start), windowTime(day, w.End)
+			// split at midnight: the part after it is counted on the next day
+			if ws.Before(next) && i >= 0 {
+				c.days[i] = append(c.days[i], segment{ws.UnixNano(), minTime(we, next).UnixNano()})
+			}
+			if we.After(next) && i+1 < n {
+				c.days[i+1] = append(c.days[i+1], segment{maxTime(ws, next).UnixNano(), we.UnixNano()})
+			}
+		}
+		day = next
+	}
+	c.cum = make([]int64, n+1)
+	for i, segs := range c.days {
+		sort.Slice(segs, func(a, b int) bool { return segs[a].start < segs[b].start })
+		c.cum[i+1] = c.cum[i]
+		for _, sg := range segs {
+			c.cum[i+1] += sg.end - sg.start
+		}
+	}
+	return c
+}
+
+// Schedule returns the schedule the calendar was built from
+func (c *BusinessCalendar) Schedule() *Schedule {
+	return c.schedule
+}
+
+// Duration returns the working time between start and end, like Schedule.Duration
+func (c *BusinessCalendar) Duration(start, end time.Time) time.Duration {
+	if !end.After(start) {
+		return 0
+	}
+	if c.schedule.AlwaysOn {
+		return end.Sub(start)
+	}
+	a, okA := c.workedBefore(start)
+	b, okB := c.workedBefore(end)
+	if !okA || !okB {
+		return c.schedule.Duration(start.In(c.loc), end, c.holidays)
+	}
+	return time.Duration(b - a)
+}
+
+// Add returns the wall-clock time at which d of working time has elapsed after start,
+// skipping off-hours, non-working days and holidays. It is the inverse of Duration.
+func (c *BusinessCalendar) Add(start time.Time, d time.Duration) time.Time {
+	if d <= 0 {
+		return start
+	}
+	if c.schedule.AlwaysOn {
+		return start.Add(d)
+	}
+	worked, ok := c.workedBefore(start)
+	if !ok {
+		return c.schedule.Add(start.In(c.loc), d, c.holidays)
+	}
+	target := worked + int64(d)
+	// first day whose cumulative working time reaches the target
+	i := sort.Search(len(c.days), func(i int) bool { return c.cum[i+1] >= target })
+	if i == len(c.days) {
+		return c.schedule.Add(start.In(c.loc), d, c.holidays)
+	}
+	remaining := target - c.cum[i]
+	for _, sg := range c.days[i] {
+		if length := sg.end - sg.start; remaining > length {
+			remaining -= length
+			continue
+		}
+		return time.Unix(0, sg.start+remaining).In(c.loc)
+	}
+	return c.schedule.Add(start.In(c.loc), d, c.holidays)
+}
+
+// workedBefore returns the working time (ns) between the start of the range and t; ok is false outside the range
+func (c *BusinessCalendar) workedBefore(t time.Time) (int64, bool) {
+	i := civilDay(t.In(c.loc)) - c.firstDay
+	if i < 0 || i >= int64(len(c.days)) {
+		return 0, false
+	}
+	ns := t.UnixNano()
+	worked := c.cum[i]
+	for _, sg := range c.days[i] {
+		if ns >= sg.end {
+			worked += sg.end - sg.start
+		} else if ns > sg.start {
+			worked += ns - sg.start
+		}
+	}
+	return worked, true
+}
+
+// civilDay returns the number of days between 1970-01-01 and t's calendar date in t's location
+func civilDay(t time.Time) int64 {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
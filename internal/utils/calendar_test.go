@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// testCalendarSchedules mix day, split, overnight and weekend windows
+func testCalendarSchedules(t testing.TB) []*Schedule {
+	weekend := NewSchedule("weekend", []time.Weekday{time.Saturday, time.Sunday}, mustWindows(t, "10:00-14:00"))
+	return []*Schedule{
+		NewWeekdaySchedule("office", mustWindows(t, "08:00-17:00")),
+		NewWeekdaySchedule("split", mustWindows(t, "08:00-12:00", "13:00-17:00")),
+		NewSchedule("nightly", allWeek, mustWindows(t, "22:00-06:00")),
+		weekend,
+	}
+}
+
+func testCalendarHolidays(t testing.TB) Holidays {
+	h := holidays("2024-02-29", "2025-01-01", "2025-12-25", "2026-03-29", "2026-10-25")
+	h["2025-12-24"] = mustWindows(t, "08:00-12:00") // half day
+	return h
+}
+
+func TestBusinessCalendarMatchesSchedule(t *testing.T) {
+	loc := mustLocation(t, "Europe/Berlin")
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, loc)
+	to := time.Date(2027, 1, 1, 0, 0, 0, 0, loc)
+	span := to.Sub(from)
+	h := testCalendarHolidays(t)
+	rng := rand.New(rand.NewSource(1))
+	for _, s := range testCalendarSchedules(t) {
+		cal := NewBusinessCalendar(s, h, loc, from, to)
+		for i := 0; i < 5000; i++ {
+			start := from.Add(time.Duration(rng.Int63n(int64(span))))
+			end := start.Add(time.Duration(rng.Int63n(int64(30 * 24 * time.Hour))))
+			if end.After(to) {
+				end = to
+			}
+			if got, want := cal.Duration(start, end), s.Duration(start, end, h); got != want {
+				t.Fatalf("%s: Duration(%v, %v) = %v, Schedule.Duration = %v", s.Name, start, end, got, want)
+			}
+			d := time.Duration(rng.Int63n(int64(200 * time.Hour)))
+			if got, want := cal.Add(start, d), s.Add(start, d, h); !got.Equal(want) {
+				t.Fatalf("%s: Add(%v, %v) = %v, Schedule.Add = %v", s.Name, start, d, got, want)
+			}
+		}
+	}
+}
+
+func TestBusinessCalendarOutsideRange(t *testing.T) {
+	s := NewWeekdaySchedule("office", mustWindows(t, "08:00-17:00"))
+	cal := NewBusinessCalendar(s, nil, time.UTC, time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC), time.Date(2026, 1, 9, 0, 0, 0, 0, time.UTC))
+	start := time.Date(2025, 12, 29, 12, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 1, 20, 12, 0, 0, 0, time.UTC)
+	if got, want := cal.Duration(start, end), s.Duration(start, end, nil); got != want {
+		t.Errorf("Duration outside the range = %v, want %v", got, want)
+	}
+	if got, want := cal.Add(start, 100*time.Hour), s.Add(start, 100*time.Hour, nil); !got.Equal(want) {
+		t.Errorf("Add past the range = %v, want %v", got, want)
+	}
+}
+
+// benchmarkIntervals returns random multi-year intervals between 2020 and 2027
+func benchmarkIntervals(loc *time.Location) [][2]time.Time {
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, loc)
+	rng := rand.New(rand.NewSource(1))
+	intervals := make([][2]time.Time, 1024)
+	for i := range intervals {
+		start := from.Add(time.Duration(rng.Int63n(int64(365 * 24 * time.Hour))))
+		end := start.Add(time.Duration(365*24*time.Hour) + time.Duration(rng.Int63n(int64(4*365*24*time.Hour))))
+		intervals[i] = [2]time.Time{start, end}
+	}
+	return intervals
+}
+
+func BenchmarkScheduleDuration(b *testing.B) {
+	loc := mustLocation(b, "Europe/Berlin")
+	s := NewWeekdaySchedule("split", mustWindows(b, "08:00-12:00", "13:00-17:00"))
+	h := testCalendarHolidays(b)
+	intervals := benchmarkIntervals(loc)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		iv := intervals[i%len(intervals)]
+		s.Duration(iv[0], iv[1], h)
+	}
+}
+
+func BenchmarkBusinessCalendarDuration(b *testing.B) {
+	loc := mustLocation(b, "Europe/Berlin")
+	s := NewWeekdaySchedule("split", mustWindows(b, "08:00-12:00", "13:00-17:00"))
+	h := testCalendarHolidays(b)
+	intervals := benchmarkIntervals(loc)
+	cal := NewBusinessCalendar(s, h, loc, time.Date(2020, 1, 1, 0, 0, 0, 0, loc), time.Date(2027, 1, 1, 0, 0, 0, 0, loc))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		iv := intervals[i%len(intervals)]
+		cal.Duration(iv[0], iv[1])
+	}
+}
@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TimeWindow is a working interval starting on one day, in minutes since that day's midnight.
+// End is greater than 24*60 when the window crosses midnight (e.g. 22:00-06:00 is 1320-1800).
+type TimeWindow struct {
+	Start int
+	End   int
+}
+
+// ParseTimeWindow parses a "HH:MM-HH:MM" window. "24:00" is accepted as the end of the day,
+// and an end before the start means the window ends the next day (overnight shift).
+func ParseTimeWindow(s string) (TimeWindow, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return TimeWindow{}, fmt.Errorf("invalid time window %q (expected HH:MM-HH:MM)", s)
+	}
+	start, err := parseClock(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return TimeWindow{}, fmt.Errorf("invalid time window %q: %v", s, err)
+	}
+	end, err := parseClock(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return TimeWindow{}, fmt.Errorf("invalid time window %q: %v", s, err)
+	}
+	if start == 24*60 {
+		return TimeWindow{}, fmt.Errorf("invalid time window %q: start must be before 24:00", s)
+	}
+	if end == start {
+		return TimeWindow{}, fmt.Errorf("invalid time window %q: start and end are equal", s)
+	}
+	if end < start {
+		end += 24 * 60
+	}
+	return TimeWindow{Start: start, End: end}, nil
+}
+
+// parseClock parses "HH:MM" into minutes since midnight
+func parseClock(s string) (int, error) {
+	if s == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (expected HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Schedule is a named set of working windows per weekday
+type Schedule struct {
+	Name string
+	// AlwaysOn makes every minute count (24x7), including weekends and holidays
+	AlwaysOn bool
+	// Days holds the working windows, indexed by time.Weekday
+	Days [7][]TimeWindow
+}
+
+// Holidays maps a date (YYYY-MM-DD) to the windows still worked on it: nil for a full day off,
+// or explicit windows for a half-day holiday
+type Holidays map[string][]TimeWindow
+
+// HolidaySet converts a plain set of full-day holiday dates
+func HolidaySet(dates map[string]struct{}) Holidays {
+	h := make(Holidays, len(dates))
+	for d := range dates {
+		h[d] = nil
+	}
+	return h
+}
+
+// WeekdaysMondayToFriday are the default working days
+var WeekdaysMondayToFriday = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+
+// NewSchedule returns a schedule using the same windows on each of the given working days
+func NewSchedule(name string, workingDays []time.Weekday, windows []TimeWindow) *Schedule {
+	s := &Schedule{Name: name}
+	for _, d := range workingDays {
+		s.Days[d] = windows
+	}
+	return s
+}
+
+// NewWeekdaySchedule returns a schedule using the same windows from Monday to Friday
+func NewWeekdaySchedule(name string, windows []TimeWindow) *Schedule {
+	return NewSchedule(name, WeekdaysMondayToFriday, windows)
+}
+
+// Validate checks that no two windows of the week overlap, including overnight windows spilling into the next day
+func (s *Schedule) Validate() error {
+	const week = 7 * 24 * 60
+	type span struct {
+		day        time.Weekday
+		start, end int
+	}
+	var spans []span
+	for d, windows := range s.Days {
+		for _, w := range windows {
+			spans = append(spans, span{time.Weekday(d), d*24*60 + w.Start, d*24*60 + w.End})
+		}
+	}
+	for i := range spans {
+		for j := i + 1; j < len(spans); j++ {
+			a, b := spans[i], spans[j]
+			// compare on the week circle: a window spilling past Saturday midnight wraps to Sunday
+			for _, shift := range []int{-week, 0, week} {
+				if a.start < b.end+shift && b.start+shift < a.end {
+					return fmt.Errorf("windows on %s and %s overlap", a.day, b.day)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// WorkingDay returns the average working time of the schedule's working days, used to express business
+// time in business days (24h for AlwaysOn, 0 when the schedule never works)
+func (s *Schedule) WorkingDay() time.Duration {
+	if s.AlwaysOn {
+		return 24 * time.Hour
+	}
+	var total time.Duration
+	days := 0
+	for _, windows := range s.Days {
+		if len(windows) == 0 {
+			continue
+		}
+		days++
+		for _, w := range windows {
+			total += time.Duration(w.End-w.Start) * time.Minute
+		}
+	}
+	if days == 0 {
+		return 0
+	}
+	return total / time.Duration(days)
+}
+
+// windowsOn returns the windows worked on day: the schedule's windows, none on a full-day holiday,
+// or the holiday's own windows on a half-day holiday that falls on a working day
+func (s *Schedule) windowsOn(day time.Time, holidays Holidays) []TimeWindow {
+	windows := s.Days[day.Weekday()]
+	if len(windows) == 0 {
+		return nil
+	}
+	if h, isHoliday := holidays[day.Format("2006-01-02")]; isHoliday {
+		return h
+	}
+	return windows
+}
+
+// Duration calculates the working time between start and end, excluding holidays.
+// An overnight window belongs to the day it starts on: a holiday on that day cancels the whole shift.
+func (s *Schedule) Duration(start, end time.Time, holidays Holidays) time.Duration {
+	if !end.After(start) {
+		return 0
+	}
+	if s.AlwaysOn {
+		return end.Sub(start)
+	}
+	loc := start.Location()
+	end = end.In(loc)
+	var total time.Duration
+	// start one day early to catch an overnight window spilling into the start day
+	day := time.Date(start.Year(), start.Month(), start.Day()-1, 0, 0, 0, 0, loc)
+	for !day.After(end) {
+		for _, w := range s.windowsOn(day, holidays) {
+			total += overlap(start, end, windowTime(day, w.Start), windowTime(day, w.End))
+		}
+		day = time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, loc)
+	}
+	return total
+}
+
+// maxAddDays bounds Schedule.Add for schedules without any working window
+const maxAddDays = 3660
+
+// Add returns the wall-clock time at which d of working time has elapsed after start, skipping off-hours,
+// non-working days and holidays. It returns the zero time when the schedule never works.
+func (s *Schedule) Add(start time.Time, d time.Duration, holidays Holidays) time.Time {
+	if d <= 0 {
+		return start
+	}
+	if s.AlwaysOn {
+		return start.Add(d)
+	}
+	loc := start.Location()
+	remaining := d
+	// start one day early to catch an overnight window spilling into the start day
+	day := time.Date(start.Year(), start.Month(), start.Day()-1, 0, 0, 0, 0, loc)
+	for i := 0; i < maxAddDays; i++ {
+		windows := append([]TimeWindow{}, s.windowsOn(day, holidays)...)
+		sort.Slice(windows, func(a, b int) bool { return windows[a].Start < windows[b].Start })
+		for _, w := range windows {
+			ws, we := windowTime(day, w.Start), windowTime(day, w.End)
+			if !we.After(start) {
+				continue
+			}
+			if ws.Before(start) {
+				ws = start
+			}
+			if avail := we.Sub(ws); remaining > avail {
+				remaining -= avail
+				continue
+			}
+			return ws.Add(remaining)
+		}
+		day = time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, loc)
+	}
+	return time.Time{}
+}
+
+// windowTime returns the wall-clock time minutes after midnight of day (minutes past 24:00 fall on the next day)
+func windowTime(day time.Time, minutes int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, minutes, 0, 0, day.Location())
+}
+
+// overlap returns the length of the intersection of [aStart, aEnd) and [bStart, bEnd)
+func overlap(aStart, aEnd, bStart, bEnd time.Time) time.Duration {
+	if bStart.After(aStart) {
+		aStart = bStart
+	}
+	if bEnd.Before(aEnd) {
+		aEnd = bEnd
+	}
+	if !aEnd.After(aStart) {
+		return 0
+	}
+	return aEnd.Sub(aStart)
+}
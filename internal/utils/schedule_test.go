@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+var allWeek = []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday}
+
+func mustWindows(t testing.TB, specs ...string) []TimeWindow {
+	t.Helper()
+	windows := make([]TimeWindow, len(specs))
+	for i, s := range specs {
+		w, err := ParseTimeWindow(s)
+		if err != nil {
+			t.Fatalf("ParseTimeWindow(%q): %v", s, err)
+		}
+		windows[i] = w
+	}
+	return windows
+}
+
+func mustLocation(t testing.TB, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s not available: %v", name, err)
+	}
+	return loc
+}
+
+func at(t testing.TB, loc *time.Location, s string) time.Time {
+	t.Helper()
+	d, err := time.ParseInLocation("2006-01-02 15:04", s, loc)
+	if err != nil {
+		t.Fatalf("bad test time %q: %v", s, err)
+	}
+	return d
+}
+
+func holidays(dates ...string) Holidays {
+	h := make(Holidays, len(dates))
+	for _, d := range dates {
+		h[d] = nil
+	}
+	return h
+}
+
+func TestParseTimeWindow(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    TimeWindow
+		wantErr bool
+	}{
+		{in: "08:00-17:00", want: TimeWindow{480, 1020}},
+		{in: "22:00-06:00", want: TimeWindow{1320, 1800}},
+		{in: "00:00-24:00", want: TimeWindow{0, 1440}},
+		{in: "18:00-24:00", want: TimeWindow{1080, 1440}},
+		{in: " 08:30 - 12:15 ", want: TimeWindow{510, 735}},
+		{in: "24:00-06:00", wantErr: true},
+		{in: "08:00-08:00", wantErr: true},
+		{in: "08:00", wantErr: true},
+		{in: "8-17", wantErr: true},
+		{in: "25:00-26:00", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTimeWindow(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTimeWindow(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseTimeWindow(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	night := NewWeekdaySchedule("night", mustWindows(t, "22:00-06:00"))
+	split := NewWeekdaySchedule("split", mustWindows(t, "08:00-12:00", "13:00-17:00"))
+	overlapSameDay := NewWeekdaySchedule("overlap", mustWindows(t, "08:00-12:00", "11:00-17:00"))
+	overlapNextDay := NewWeekdaySchedule("spill", mustWindows(t, "22:00-06:00"))
+	overlapNextDay.Days[time.Tuesday] = append(overlapNextDay.Days[time.Tuesday], mustWindows(t, "05:00-07:00")...)
+	wrapWeek := &Schedule{Name: "wrap"}
+	wrapWeek.Days[time.Saturday] = mustWindows(t, "22:00-06:00")
+	wrapWeek.Days[time.Sunday] = mustWindows(t, "05:00-09:00")
+
+	tests := []struct {
+		name    string
+		s       *Schedule
+		wantErr bool
+	}{
+		{"overnight weekdays", night, false},
+		{"two windows same day", split, false},
+		{"overlap same day", overlapSameDay, true},
+		{"overnight spills into next day window", overlapNextDay, true},
+		{"saturday night spills into sunday", wrapWeek, true},
+	}
+	for _, tt := range tests {
+		if err := tt.s.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestScheduleDuration(t *testing.T) {
+	utc := time.UTC
+	berlin := mustLocation(t, "Europe/Berlin")
+
+	office := NewWeekdaySchedule("office", mustWindows(t, "08:00-17:00"))
+	night := NewWeekdaySchedule("night", mustWindows(t, "22:00-06:00"))
+	split := NewWeekdaySchedule("split", mustWindows(t, "08:00-12:00", "13:00-17:00"))
+	nightly := NewSchedule("nightly", allWeek, mustWindows(t, "22:00-06:00"))
+	early := NewSchedule("early", allWeek, mustWindows(t, "00:00-06:00"))
+
+	// 2026-01-05 is a Monday; 2024-02-29 a Thursday; 2028-02-29 a Tuesday
+	tests := []struct {
+		name       string
+		s          *Schedule
+		h          Holidays
+		loc        *time.Location
+		start, end string
+		want       time.Duration
+	}{
+		// overnight shift boundaries
+		{"whole overnight shift", night, nil, utc, "2026-01-05 22:00", "2026-01-06 06:00", 8 * time.Hour},
+		{"start inside shift", night, nil, utc, "2026-01-05 23:30", "2026-01-06 06:00", 6*time.Hour + 30*time.Minute},
+		{"start inside shift after midnight", night, nil, utc, "2026-01-06 03:00", "2026-01-06 12:00", 3 * time.Hour},
+		{"end inside shift", night, nil, utc, "2026-01-05 20:00", "2026-01-06 02:00", 4 * time.Hour},
+		{"start and end inside shift", night, nil, utc, "2026-01-05 23:00", "2026-01-06 01:00", 2 * time.Hour},
+		{"before shift", night, nil, utc, "2026-01-05 12:00", "2026-01-05 22:00", 0},
+		{"after shift", night, nil, utc, "2026-01-06 06:00", "2026-01-06 21:59", 0},
+		{"across shift", night, nil, utc, "2026-01-05 12:00", "2026-01-06 12:00", 8 * time.Hour},
+		{"friday shift spills into saturday", night, nil, utc, "2026-01-09 12:00", "2026-01-10 12:00", 8 * time.Hour},
+		{"no shift starts on sunday", night, nil, utc, "2026-01-11 00:00", "2026-01-12 12:00", 0},
+		{"end before start", night, nil, utc, "2026-01-06 06:00", "2026-01-05 22:00", 0},
+
+		// a holiday cancels the shift starting on it, not the one ending on it
+		{"holiday on shift start day", night, holidays("2026-01-05"), utc, "2026-01-05 12:00", "2026-01-06 12:00", 0},
+		{"holiday on shift end day", night, holidays("2026-01-06"), utc, "2026-01-05 12:00", "2026-01-06 12:00", 8 * time.Hour},
+		{"holiday on shift end day cancels that night", night, holidays("2026-01-06"), utc, "2026-01-05 12:00", "2026-01-07 12:00", 8 * time.Hour},
+
+		// DST: 2026-03-29 02:00 -> 03:00 and 2026-10-25 03:00 -> 02:00 in Europe/Berlin
+		{"spring forward window", early, nil, berlin, "2026-03-29 00:00", "2026-03-29 12:00", 5 * time.Hour},
+		{"fall back window", early, nil, berlin, "2026-10-25 00:00", "2026-10-25 12:00", 7 * time.Hour},
+		{"overnight shift over spring forward", nightly, nil, berlin, "2026-03-28 12:00", "2026-03-29 12:00", 7 * time.Hour},
+		{"overnight shift over fall back", nightly, nil, berlin, "2026-10-24 12:00", "2026-10-25 12:00", 9 * time.Hour},
+		{"office day after spring forward", office, nil, berlin, "2026-03-27 08:00", "2026-03-30 17:00", 18 * time.Hour},
+
+		// leap days
+		{"leap day 2024", office, nil, utc, "2024-02-28 12:00", "2024-03-01 12:00", 18 * time.Hour},
+		{"leap day 2024 holiday", office, holidays("2024-02-29"), utc, "2024-02-28 12:00", "2024-03-01 12:00", 9 * time.Hour},
+		{"leap day 2028", office, nil, utc, "2028-02-28 12:00", "2028-03-01 12:00", 18 * time.Hour},
+		{"overnight shift into leap day 2024", night, nil, utc, "2024-02-28 23:00", "2024-02-29 23:00", 8 * time.Hour},
+		{"overnight shift out of leap day 2028", night, nil, utc, "2028-02-29 12:00", "2028-03-01 12:00", 8 * time.Hour},
+
+		// two windows on the same day
+		{"across lunch break", split, nil, utc, "2026-01-05 10:00", "2026-01-05 14:00", 3 * time.Hour},
+		{"inside lunch break", split, nil, utc, "2026-01-05 12:00", "2026-01-05 13:00", 0},
+		{"whole split day", split, nil, utc, "2026-01-05 00:00", "2026-01-06 00:00", 8 * time.Hour},
+	}
+	for _, tt := range tests {
+		got := tt.s.Duration(at(t, tt.loc, tt.start), at(t, tt.loc, tt.end), tt.h)
+		if got != tt.want {
+			t.Errorf("%s: Duration(%s, %s) = %v, want %v", tt.name, tt.start, tt.end, got, tt.want)
+		}
+	}
+}
+
+func TestScheduleAdd(t *testing.T) {
+	utc := time.UTC
+	berlin := mustLocation(t, "Europe/Berlin")
+
+	night := NewWeekdaySchedule("night", mustWindows(t, "22:00-06:00"))
+	split := NewWeekdaySchedule("split", mustWindows(t, "08:00-12:00", "13:00-17:00"))
+	office := NewWeekdaySchedule("office", mustWindows(t, "08:00-17:00"))
+	nightly := NewSchedule("nightly", allWeek, mustWindows(t, "22:00-06:00"))
+
+	tests := []struct {
+		name  string
+		s     *Schedule
+		h     Holidays
+		loc   *time.Location
+		start string
+		d     time.Duration
+		want  string
+	}{
+		{"before shift", night, nil, utc, "2026-01-05 21:00", time.Hour, "2026-01-05 23:00"},
+		{"inside shift past midnight", night, nil, utc, "2026-01-05 23:00", 3 * time.Hour, "2026-01-06 02:00"},
+		{"to the end of the shift", night, nil, utc, "2026-01-05 22:00", 8 * time.Hour, "2026-01-06 06:00"},
+		{"into the next shift", night, nil, utc, "2026-01-06 05:00", 2 * time.Hour, "2026-01-06 23:00"},
+		{"after shift", night, nil, utc, "2026-01-06 07:00", time.Hour, "2026-01-06 23:00"},
+		{"friday shift to monday shift", night, nil, utc, "2026-01-10 05:00", 2 * time.Hour, "2026-01-12 23:00"},
+		{"holiday on shift start day", night, holidays("2026-01-06"), utc, "2026-01-06 05:00", 2 * time.Hour, "2026-01-07 23:00"},
+		{"zero duration", night, nil, utc, "2026-01-06 12:00", 0, "2026-01-06 12:00"},
+
+		{"overnight shift over spring forward", nightly, nil, berlin, "2026-03-28 22:00", 7 * time.Hour, "2026-03-29 06:00"},
+		{"overnight shift over fall back", nightly, nil, berlin, "2026-10-24 22:00", 9 * time.Hour, "2026-10-25 06:00"},
+
+		{"over leap day 2024", office, nil, utc, "2024-02-28 12:00", 18 * time.Hour, "2024-03-01 12:00"},
+		{"leap day 2024 holiday", office, holidays("2024-02-29"), utc, "2024-02-28 12:00", 9 * time.Hour, "2024-03-01 12:00"},
+		{"over leap day 2028", office, nil, utc, "2028-02-28 12:00", 18 * time.Hour, "2028-03-01 12:00"},
+		{"overnight shift into leap day 2024", night, nil, utc, "2024-02-28 23:00", 8 * time.Hour, "2024-02-29 23:00"},
+
+		{"across lunch break", split, nil, utc, "2026-01-05 11:00", 2 * time.Hour, "2026-01-05 14:00"},
+		{"from lunch break", split, nil, utc, "2026-01-05 12:30", time.Hour, "2026-01-05 14:00"},
+		{"to the next morning", split, nil, utc, "2026-01-05 16:00", 2 * time.Hour, "2026-01-06 09:00"},
+	}
+	for _, tt := range tests {
+		got := tt.s.Add(at(t, tt.loc, tt.start), tt.d, tt.h)
+		if want := at(t, tt.loc, tt.want); !got.Equal(want) {
+			t.Errorf("%s: Add(%s, %v) = %v, want %v", tt.name, tt.start, tt.d, got, want)
+		}
+	}
+}
+
+func TestScheduleAddNeverWorks(t *testing.T) {
+	s := &Schedule{Name: "never"}
+	if got := s.Add(time.Date(2026, 1, 5, 8, 0, 0, 0, time.UTC), time.Hour, nil); !got.IsZero() {
+		t.Errorf("Add on a schedule without windows = %v, want zero time", got)
+	}
+}
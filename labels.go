@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"itop-sla-exporter/internal/itop"
+)
+
+// LabelsConfig maps iTop enum codes to the names used in metric labels, e.g. priority: {"1": "Kritis"}.
+// A code is looked up in the config first, then in the names fetched from iTop, then in the built-in
+// English names; unknown codes are exported as is. iTop only names the codes some ticket uses.
+type LabelsConfig struct {
+	// FromITop fetches the display names iTop shows for the codes in use
+	FromITop        bool              `yaml:"from_itop"`
+	RefreshInterval string            `yaml:"refresh_interval"`
+	Priority        map[string]string `yaml:"priority"`
+	Urgency         map[string]string `yaml:"urgency"`
+	Impact          map[string]string `yaml:"impact"`
+	Status          map[string]string `yaml:"status"`
+}
+
+// labelAttributes are the ticket enum attributes whose codes are mapped
+var labelAttributes = []string{"priority", "urgency", "impact", "status"}
+
+// builtinLabels are the names of the standard iTop datamodel, used when neither the config nor iTop names a code
+var builtinLabels = map[string]map[string]string{
+	"priority": {"1": "Critical", "2": "High", "3": "Medium", "4": "Low"},
+	"urgency":  {"1": "Critical", "2": "High", "3": "Medium", "4": "Low"},
+	"impact":   {"1": "A department", "2": "A service", "3": "A person"},
+}
+
+var (
+	itopLabels   map[string]map[string]string // by attribute, then code
+	itopLabelsMu sync.RWMutex
+
+	labelSamples   = make(map[string]map[string]map[string]string) // by class, attribute, code: id of a ticket with it
+	labelSamplesMu sync.Mutex
+)
+
+// validateLabels defaults the refresh interval of the names fetched from iTop
+func validateLabels(cfg *LabelsConfig) error {
+	if cfg.RefreshInterval == "" {
+		cfg.RefreshInterval = "1h"
+	}
+	if d, err := parseDuration(cfg.RefreshInterval); err != nil || d <= 0 {
+		return fmt.Errorf("labels.refresh_interval: invalid duration %q (expected e.g. 1h)", cfg.RefreshInterval)
+	}
+	return nil
+}
+
+func (c LabelsConfig) mapping(attribute string) map[string]string {
+	switch attribute {
+	case "priority":
+		return c.Priority
+	case "urgency":
+		return c.Urgency
+	case "impact":
+		return c.Impact
+	case "status":
+		return c.Status
+	}
+	return nil
+}
+
+// enumLabel returns the label of an enum code, or the code itself when nothing names it
+func enumLabel(attribute, code string) string {
+	if label, ok := config.Labels.mapping(attribute)[code]; ok {
+		return label
+	}
+	itopLabelsMu.RLock()
+	label, ok := itopLabels[attribute][code]
+	itopLabelsMu.RUnlock()
+	if ok {
+		return label
+	}
+	if label, ok := builtinLabels[attribute][code]; ok {
+		return label
+	}
+	return code
+}
+
+func priorityLabel(id string) string { return enumLabel("priority", id) }
+func urgencyLabel(id string) string  { return enumLabel("urgency", id) }
+func impactLabel(id string) string   { return enumLabel("impact", id) }
+func statusLabel(id string) string   { return enumLabel("status", id) }
+
+// enumCode returns a ticket's code for one of the labelAttributes
+func enumCode(t itop.Ticket, attribute string) string {
+	switch attribute {
+	case "priority":
+		return t.Priority
+	case "urgency":
+		return t.Urgency
+	case "impact":
+		return t.Impact
+	case "status":
+		return t.Status
+	}
+	return ""
+}
+
+// noteLabelSamples records one ticket per enum code in use, for the next label refresh
+func noteLabelSamples(class string, tickets []itop.Ticket) {
+	samples := make(map[string]map[string]string)
+	for _, attr := range labelAttributes {
+		samples[attr] = make(map[string]string)
+		for _, t := range tickets {
+			if code := enumCode(t, attr); code != "" && samples[attr][code] == "" {
+				samples[attr][code] = t.ID
+			}
+		}
+	}
+	labelSamplesMu.Lock()
+	labelSamples[class] = samples
+	labelSamplesMu.Unlock()
+}
+
+func haveLabelSamples() bool {
+	labelSamplesMu.Lock()
+	defer labelSamplesMu.Unlock()
+	return len(labelSamples) > 0
+}
+
+// refreshITopLabels fetches the display names of the codes in use in both ticket classes and swaps them in.
+// Names already known are kept, so a code that went out of use keeps its name.
+func refreshITopLabels() error {
+	merged := make(map[string]map[string]string)
+	itopLabelsMu.RLock()
+	for attr, codes := range itopLabels {
+		merged[attr] = make(map[string]string, len(codes))
+		for code, label := range codes {
+			merged[attr][code] = label
+		}
+	}
+	itopLabelsMu.RUnlock()
+	for _, class := range []string{"Incident", "UserRequest"} {
+		labelSamplesMu.Lock()
+		samples := labelSamples[class]
+		labelSamplesMu.Unlock()
+		if len(samples) == 0 {
+			continue // not fetched yet
+		}
+		labels, err := itop.FetchEnumLabels(class, samples)
+		if err != nil {
+			return fmt.Errorf("%s: %v", class, err)
+		}
+		for attr, codes := range labels {
+			if merged[attr] == nil {
+				merged[attr] = make(map[string]string)
+			}
+			for code, label := range codes {
+				merged[attr][code] = label
+			}
+		}
+	}
+	itopLabelsMu.Lock()
+	itopLabels = merged
+	itopLabelsMu.Unlock()
+	return nil
+}
+
+// syncITopLabels periodically refreshes the names fetched from iTop; the previous names are kept on error.
+// The first refresh waits for the ticket fetchers to record the codes in use.
+func syncITopLabels(interval time.Duration) {
+	for !haveLabelSamples() {
+		time.Sleep(time.Second)
+	}
+	for {
+		if err := refreshITopLabels(); err != nil {
+			log.Printf("Failed to fetch labels from iTop: %v", err)
+		}
+		time.Sleep(interval)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -11,53 +12,30 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"gopkg.in/yaml.v2"
 
 	"itop-sla-exporter/internal/itop"
-	"itop-sla-exporter/internal/utils"
 )
 
-// Konversi impact 1-3 ke string
-
-type Config struct {
-	WorkHours struct {
-		Start string `yaml:"start"`
-		End   string `yaml:"end"`
-	} `yaml:"work_hours"`
-	// Holidays removed: now loaded from holidays.txt
-	SLADeadlines map[string]map[string]struct {
-		Response string `yaml:"response"`
-		Resolve  string `yaml:"resolve"`
-	} `yaml:"sla_deadlines"`
-}
-
-func impactLabel(id string) string {
-	switch id {
-	case "1":
-		return "A department"
-	case "2":
-		return "A service"
-	case "3":
-		return "A person"
-	default:
-		return id
-	}
-}
-
 // Fungsi summary metrics updater
 
 func updateSummaryMetrics(tickets []itop.Ticket) {
 	ticketCount.Reset()
 	slaCompliance.Reset()
+	slaWarning.Reset()
+	slaComplianceComputed.Reset()
+	priorityChangeCount.Reset()
+	reopenedCount.Reset()
+	reassignmentCount.Reset()
+	teamHandoffCount.Reset()
+	teamTimeSeconds.Reset()
+	olaCompliance.Reset() // olaDuration is a histogram, never reset: see olaObserved
+	backlogAgeCount.Reset()
+	oldestOpenAge.Reset()
+	ticketFlowCount.Reset()
+	backlogNetChange.Reset()
 
 	// Load holidays from file (sync with iTop)
-	holidaysList, _ := itop.LoadHolidaysFromFile("holidays.txt")
-	holidays := make(map[string]struct{})
-	for _, h := range holidaysList {
-		holidays[h] = struct{}{}
-	}
-	workStart := config.WorkHours.Start
-	workEnd := config.WorkHours.End
+	cals := newCalendarSet(holidayStore.Sets())
 
 	type agg struct {
 		sumResponse float64
@@ -67,12 +45,17 @@ func updateSummaryMetrics(tickets []itop.Ticket) {
 	avgRespMap := make(map[string]*agg)
 	avgResMap := make(map[string]*agg)
 	monthlyMap := make(map[string]float64)
+	var mismatches []slaMismatch
+	var priorityMismatchList []priorityMismatch
+	now := itopNow(config) // iTop's wall clock, like the ticket dates
+	backlog := newBacklogAges(now)
+	flow := newFlowWindow(now)
 
 	for _, t := range tickets {
 		prio := priorityLabel(t.Priority)
 		urg := urgencyLabel(t.Urgency)
 		ticketCount.WithLabelValues(
-			t.Status, t.Class, t.Service, t.ServiceSubcategory, t.Team, t.Agent, prio, urg,
+			statusLabel(t.Status), t.Class, t.Service, t.ServiceSubcategory, t.Team, t.Agent, prio, urg,
 		).Inc()
 
 		// Monthly ticket count
@@ -80,6 +63,9 @@ func updateSummaryMetrics(tickets []itop.Ticket) {
 		monthlyKey := strings.Join([]string{month, t.Class, t.Status, t.Agent, t.Team}, "|")
 		monthlyMap[monthlyKey]++
 
+		// Opened / resolved / closed per day and week
+		flow.add(t)
+
 		// Histogram & average
 		ttrRaw := t.ResolutionDate.Sub(t.StartDate).Seconds()
 		ttoRaw := t.AssignmentDate.Sub(t.StartDate).Seconds()
@@ -99,139 +85,154 @@ func updateSummaryMetrics(tickets []itop.Ticket) {
 			avgResMap[key].count++
 		}
 
+		cal := cals.ForTicket(t)
+		sched := cal.Schedule()
+
 		// Ticket age (for open/assigned tickets)
+		backlog.add(t, cal)
+
+		res := evaluateSLA(t, cal)
+		for _, engine := range slaEngines() {
+			switch engine {
+			case engineExporter:
+				// RAW calculation
+				addCompliance(t.Class, prio, urg, "raw", "response", engine, "", res.ComplyResponseRaw)
+				addCompliance(t.Class, prio, urg, "raw", "resolve", engine, "", res.ComplyResolveRaw)
+				// BUSINESS-HOUR calculation
+				addCompliance(t.Class, prio, urg, "business-hour", "response", engine, sched.Name, res.ComplyResponseBH)
+				addCompliance(t.Class, prio, urg, "business-hour", "resolve", engine, sched.Name, res.ComplyResolveBH)
+			case engineITop:
+				// iTop stopwatches already run on the coverage window, so they count as business-hour
+				sw := evaluateITopSLA(t, now)
+				addCompliance(t.Class, prio, urg, "business-hour", "response", engine, "", sw.ComplyResponse)
+				addCompliance(t.Class, prio, urg, "business-hour", "resolve", engine, "", sw.ComplyResolve)
+				if sw.WarningResponse {
+					slaWarning.WithLabelValues(t.Class, prio, urg, "response").Inc()
+				}
+				if sw.WarningResolve {
+					slaWarning.WithLabelValues(t.Class, prio, urg, "resolve").Inc()
+				}
+			}
+		}
 
-		// SLA deadline from iTop (not config, now cached)
-		slt, err := itop.GetSLTDeadlineCached(t.Class, t.Priority, t.Service)
-		var responseDeadline, resolveDeadline time.Duration
-		if err == nil {
-			responseDeadline = slt.TTO
-			resolveDeadline = slt.TTR
-			// log.Printf("SLT fetched for class=%s, priority=%s, service=%s: TTO=%v, TTR=%v", t.Class, t.Priority, t.Service, slt.TTO, slt.TTR)
-		} else {
-			// log.Printf("SLT fetch error for class=%s, priority=%s, service=%s: %v", t.Class, t.Priority, t.Service, err)
+		if config.SLAPolicy.Priority != "" {
+			priorityChangeCount.WithLabelValues(t.Class, config.SLAPolicy.Priority).Add(float64(len(t.Changes("priority"))))
 		}
 
-		// RAW calculation
-		complyResponseRaw := 0.0
-		complyResolveRaw := 0.0
-		if responseDeadline > 0 && ttoRaw > 0 {
-			if ttoRaw <= responseDeadline.Seconds() {
-				complyResponseRaw = 1.0
-			}
+		if config.SLAPolicy.Reopen != "" && len(reopens(t)) > 0 {
+			reopenedCount.WithLabelValues(t.Class, t.Team, t.Service).Inc()
 		}
-		if resolveDeadline > 0 && ttrRaw > 0 {
-			if ttrRaw <= resolveDeadline.Seconds() {
-				complyResolveRaw = 1.0
-			}
+
+		if config.Reassignment.Enabled {
+			addReassignmentMetrics(t, cal, now)
 		}
-		slaCompliance.WithLabelValues(t.Class, prio, urg, "raw", "response", "comply").Add(complyResponseRaw)
-		slaCompliance.WithLabelValues(t.Class, prio, urg, "raw", "response", "violate").Add(1.0 - complyResponseRaw)
-		slaCompliance.WithLabelValues(t.Class, prio, urg, "raw", "resolve", "comply").Add(complyResolveRaw)
-		slaCompliance.WithLabelValues(t.Class, prio, urg, "raw", "resolve", "violate").Add(1.0 - complyResolveRaw)
-
-		// BUSINESS-HOUR calculation
-		ttrBH := utils.CalculateBusinessHourDuration(t.StartDate, t.ResolutionDate, workStart, workEnd, holidays).Seconds()
-		ttoBH := utils.CalculateBusinessHourDuration(t.StartDate, t.AssignmentDate, workStart, workEnd, holidays).Seconds()
-		complyResponseBH := 0.0
-		complyResolveBH := 0.0
-		// Perbaikan: jika ttoBH == 0 tapi ttoRaw <= deadline, tetap comply (response sebelum jam kerja)
-		if responseDeadline > 0 && ((ttoBH > 0 && ttoBH <= responseDeadline.Seconds()) || (ttoBH == 0 && ttoRaw > 0 && ttoRaw <= responseDeadline.Seconds())) {
-			complyResponseBH = 1.0
+		if len(config.OLA.Targets) > 0 {
+			addOLAMetrics(t, cal, now)
 		}
-		// Perbaikan: jika ttrBH == 0 tapi ttrRaw <= deadline, tetap comply (resolve sebelum jam kerja)
-		if resolveDeadline > 0 && ((ttrBH > 0 && ttrBH <= resolveDeadline.Seconds()) || (ttrBH == 0 && ttrRaw > 0 && ttrRaw <= resolveDeadline.Seconds())) {
-			complyResolveBH = 1.0
+
+		// Stored priority vs impact×urgency matrix
+		if m, ok := checkPriority(t); ok {
+			priorityMismatchList = append(priorityMismatchList, m)
+		}
+		if config.PriorityMatrix.AuditSLA {
+			auditSLA(t, cal)
+		}
+
+		// Reconciliation against iTop's own sla_tto_passed / sla_ttr_passed flags
+		base := slaMismatch{ID: t.ID, Ref: t.Ref, Class: t.Class, Service: t.Service, Priority: prio}
+		if m, ok := reconcileSLA(base, "response", t.SLATTOPassed, res.TTOBH, res.TTORaw, res.ResponseDeadline.Seconds(), res.ComplyResponseBH); ok {
+			mismatches = append(mismatches, m)
+		}
+		if m, ok := reconcileSLA(base, "resolve", t.SLATTRPassed, res.TTRBH, res.TTRRaw, res.ResolveDeadline.Seconds(), res.ComplyResolveBH); ok {
+			mismatches = append(mismatches, m)
 		}
-		slaCompliance.WithLabelValues(t.Class, prio, urg, "business-hour", "response", "comply").Add(complyResponseBH)
-		slaCompliance.WithLabelValues(t.Class, prio, urg, "business-hour", "response", "violate").Add(1.0 - complyResponseBH)
-		slaCompliance.WithLabelValues(t.Class, prio, urg, "business-hour", "resolve", "comply").Add(complyResolveBH)
-		slaCompliance.WithLabelValues(t.Class, prio, urg, "business-hour", "resolve", "violate").Add(1.0 - complyResolveBH)
 	}
+	setSLAMismatches(mismatches)
+	setPriorityMismatches(priorityMismatchList)
+	backlog.flush()
 
 	// Set average metrics
 }
 
 // Fungsi set metric detail per ticket
-func setTicketDetailMetric(t itop.Ticket) {
-	prio := priorityLabel(t.Priority)
-	urg := urgencyLabel(t.Urgency)
-	var ttrRaw, ttoRaw, ttrBH, ttoBH float64
-	var startDateStr, assignmentDateStr, resolutionDateStr string
-	workStart := config.WorkHours.Start
-	workEnd := config.WorkHours.End
-	holidaysList, _ := itop.LoadHolidaysFromFile("holidays.txt")
-	holidays := make(map[string]struct{})
-	for _, h := range holidaysList {
-		holidays[h] = struct{}{}
-	}
-	if t.StartDate.IsZero() {
-		startDateStr = ""
-	} else {
-		startDateStr = fmt.Sprintf("%d", t.StartDate.Unix())
-	}
-	if t.AssignmentDate.IsZero() {
-		assignmentDateStr = ""
-	} else {
-		assignmentDateStr = fmt.Sprintf("%d", t.AssignmentDate.Unix())
-	}
-	if t.ResolutionDate.IsZero() {
-		resolutionDateStr = ""
-	} else {
-		resolutionDateStr = fmt.Sprintf("%d", t.ResolutionDate.Unix())
-	}
-	if t.StartDate.IsZero() || t.AssignmentDate.IsZero() {
-		ttoRaw = 0
-		ttoBH = 0
-	} else {
-		ttoRaw = t.AssignmentDate.Sub(t.StartDate).Seconds()
-		ttoBH = utils.CalculateBusinessHourDuration(t.StartDate, t.AssignmentDate, workStart, workEnd, holidays).Seconds()
-	}
-	if t.StartDate.IsZero() || t.ResolutionDate.IsZero() {
-		ttrRaw = 0
-		ttrBH = 0
-	} else {
-		ttrRaw = t.ResolutionDate.Sub(t.StartDate).Seconds()
-		ttrBH = utils.CalculateBusinessHourDuration(t.StartDate, t.ResolutionDate, workStart, workEnd, holidays).Seconds()
+func setTicketDetailMetric(t itop.Ticket, cals *calendarSet) {
+	base := ticketDetailLabels(t)
+	seconds := func(v float64) string { return fmt.Sprintf("%.0f", v) }
+	emit := func(tto, ttr string, slaType, slaMetric string, comply bool, engine, schedule string) {
+		labels := append(append([]string{}, base...),
+			tto,
+			ttr,
+			slaType,
+			slaMetric,
+			complianceStatus(comply),
+			engine,
+			schedule,
+		)
+		ticketDetailInfo.WithLabelValues(labels...).Set(1)
 	}
 
-	// Ambil SLT deadline dari cache
-	slt, err := itop.GetSLTDeadlineCached(t.Class, t.Priority, t.Service)
-	var responseDeadline, resolveDeadline time.Duration
-	if err == nil {
-		responseDeadline = slt.TTO
-		resolveDeadline = slt.TTR
+	// Compliance logic per metric
+	cal := cals.ForTicket(t)
+	sched := cal.Schedule()
+	res := evaluateSLA(t, cal)
+	for _, engine := range slaEngines() {
+		switch engine {
+		case engineExporter:
+			emit(seconds(res.TTOBH), seconds(res.TTRBH), "business-hour", "response", res.ComplyResponseBH, engine, sched.Name)
+			emit(seconds(res.TTOBH), seconds(res.TTRBH), "business-hour", "resolve", res.ComplyResolveBH, engine, sched.Name)
+			emit(seconds(res.TTORaw), seconds(res.TTRRaw), "raw", "response", res.ComplyResponseRaw, engine, "")
+			emit(seconds(res.TTORaw), seconds(res.TTRRaw), "raw", "resolve", res.ComplyResolveRaw, engine, "")
+		case engineITop:
+			// iTop's verdict comes from its stopwatches, whose time spent the exporter does not fetch:
+			// leave the durations empty rather than mix raw seconds into business-hour rows
+			sw := evaluateITopSLA(t, itopNow(config))
+			emit("", "", "business-hour", "response", sw.ComplyResponse, engine, "")
+			emit("", "", "business-hour", "resolve", sw.ComplyResolve, engine, "")
+		}
 	}
 
-	// Compliance logic per metric
-	slaComplyRawResp := "violate"
-	slaComplyRawRes := "violate"
-	slaComplyBHResp := "violate"
-	slaComplyBHRes := "violate"
-	if responseDeadline > 0 && ttoRaw > 0 && ttoRaw <= responseDeadline.Seconds() {
-		slaComplyRawResp = "comply"
+	setPriorityChangeMetric(t)
+	setReopenMetric(t)
+	setReassignmentMetric(t)
+
+	// "Due by" timestamps: raw adds the SLT as wall-clock time, business-hour skips off-hours.
+	// The deadlines are iTop wall clock; itopUnix turns them into real Unix seconds.
+	if !t.StartDate.IsZero() {
+		if res.ResponseDeadline > 0 {
+			ticketTTODue.WithLabelValues(t.ID, t.Ref, t.Class, "raw").Set(float64(itopUnix(config, t.StartDate.Add(res.ResponseDeadline))))
+			if due := cal.Add(t.StartDate, res.ResponseDeadline); !due.IsZero() {
+				ticketTTODue.WithLabelValues(t.ID, t.Ref, t.Class, "business-hour").Set(float64(itopUnix(config, due)))
+			}
+		}
+		if res.ResolveDeadline > 0 {
+			ticketTTRDue.WithLabelValues(t.ID, t.Ref, t.Class, "raw").Set(float64(itopUnix(config, res.ResolveStart.Add(res.ResolveDeadline))))
+			if due := cal.Add(res.ResolveStart, res.ResolveDeadline); !due.IsZero() {
+				ticketTTRDue.WithLabelValues(t.ID, t.Ref, t.Class, "business-hour").Set(float64(itopUnix(config, due)))
+			}
+		}
 	}
-	if resolveDeadline > 0 && ttrRaw > 0 && ttrRaw <= resolveDeadline.Seconds() {
-		slaComplyRawRes = "comply"
+}
+
+// ticketDetailLabels returns the leading itop_ticket_detail_info label values, which describe the ticket itself
+func ticketDetailLabels(t itop.Ticket) []string {
+	var startDateStr, assignmentDateStr, resolutionDateStr string
+	if !t.StartDate.IsZero() {
+		startDateStr = fmt.Sprintf("%d", t.StartDate.Unix())
 	}
-	// Perbaikan: jika ttoBH == 0 tapi ttoRaw <= deadline, tetap comply (response sebelum jam kerja)
-	if responseDeadline > 0 && ((ttoBH > 0 && ttoBH <= responseDeadline.Seconds()) || (ttoBH == 0 && ttoRaw > 0 && ttoRaw <= responseDeadline.Seconds())) {
-		slaComplyBHResp = "comply"
+	if !t.AssignmentDate.IsZero() {
+		assignmentDateStr = fmt.Sprintf("%d", t.AssignmentDate.Unix())
 	}
-	// Perbaikan: jika ttrBH == 0 tapi ttrRaw <= deadline, tetap comply (resolve sebelum jam kerja)
-	if resolveDeadline > 0 && ((ttrBH > 0 && ttrBH <= resolveDeadline.Seconds()) || (ttrBH == 0 && ttrRaw > 0 && ttrRaw <= resolveDeadline.Seconds())) {
-		slaComplyBHRes = "comply"
+	if !t.ResolutionDate.IsZero() {
+		resolutionDateStr = fmt.Sprintf("%d", t.ResolutionDate.Unix())
 	}
-
-	// Emit business-hour metric (response)
-	ticketDetailInfo.WithLabelValues(
+	return []string{
 		t.ID,
 		t.Ref,
 		t.Class,
 		t.Title,
-		t.Status,
-		prio,
-		urg,
+		statusLabel(t.Status),
+		priorityLabel(t.Priority),
+		urgencyLabel(t.Urgency),
 		impactLabel(t.Impact),
 		t.Service,
 		t.ServiceSubcategory,
@@ -242,110 +243,23 @@ func setTicketDetailMetric(t itop.Ticket) {
 		startDateStr,
 		assignmentDateStr,
 		resolutionDateStr,
-		fmt.Sprintf("%.0f", ttoBH),
-		fmt.Sprintf("%.0f", ttrBH),
-		"business-hour",
-		"response",
-		slaComplyBHResp,
-	).Set(1)
-
-	// Emit business-hour metric (resolve)
-	ticketDetailInfo.WithLabelValues(
-		t.ID,
-		t.Ref,
-		t.Class,
-		t.Title,
-		t.Status,
-		prio,
-		urg,
-		impactLabel(t.Impact),
-		t.Service,
-		t.ServiceSubcategory,
-		t.Agent,
-		t.Team,
-		t.Caller, // caller_id_friendlyname
-		t.Origin, // origin
-		startDateStr,
-		assignmentDateStr,
-		resolutionDateStr,
-		fmt.Sprintf("%.0f", ttoBH),
-		fmt.Sprintf("%.0f", ttrBH),
-		"business-hour",
-		"resolve",
-		slaComplyBHRes,
-	).Set(1)
-
-	// Emit raw metric (response)
-	ticketDetailInfo.WithLabelValues(
-		t.ID,
-		t.Ref,
-		t.Class,
-		t.Title,
-		t.Status,
-		prio,
-		urg,
-		impactLabel(t.Impact),
-		t.Service,
-		t.ServiceSubcategory,
-		t.Agent,
-		t.Team,
-		t.Caller, // caller_id_friendlyname
-		t.Origin, // origin
-		startDateStr,
-		assignmentDateStr,
-		resolutionDateStr,
-		fmt.Sprintf("%.0f", ttoRaw),
-		fmt.Sprintf("%.0f", ttrRaw),
-		"raw",
-		"response",
-		slaComplyRawResp,
-	).Set(1)
-
-	// Emit raw metric (resolve)
-	ticketDetailInfo.WithLabelValues(
-		t.ID,
-		t.Ref,
-		t.Class,
-		t.Title,
-		t.Status,
-		prio,
-		urg,
-		impactLabel(t.Impact),
-		t.Service,
-		t.ServiceSubcategory,
-		t.Agent,
-		t.Team,
-		t.Caller, // caller_id_friendlyname
-		t.Origin, // origin
-		startDateStr,
-		assignmentDateStr,
-		resolutionDateStr,
-		fmt.Sprintf("%.0f", ttoRaw),
-		fmt.Sprintf("%.0f", ttrRaw),
-		"raw",
-		"resolve",
-		slaComplyRawRes,
-	).Set(1)
-}
-
-var config Config
-
-func loadConfig(path string) error {
-	f, err := os.Open(path)
-	if err != nil {
-		return err
 	}
-	defer f.Close()
-	decoder := yaml.NewDecoder(f)
-	return decoder.Decode(&config)
 }
 
 func main() {
-	// Load config
-	err := loadConfig("config/business_hours.yaml")
+	// Load config: YAML file, ITOP_EXPORTER_* env vars, then flags
+	opts, err := parseCLI(os.Args[1:])
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
 	if err != nil {
+		log.Fatalf("Invalid arguments: %v", err)
+	}
+	cliOpts = opts
+	if err := loadConfig(opts.ConfigPath, opts.Overrides); err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	itop.APIVersion = config.ITopAPIVersion
 
 	// Registries for each endpoint
 	regSummary := prometheus.NewRegistry()
@@ -355,9 +269,18 @@ func main() {
 	// Register metrics for each registry
 	regSummary.MustRegister(ticketCount)
 	regSummary.MustRegister(slaCompliance)
+	regSummary.MustRegister(slaMismatchCount)
+	regSummary.MustRegister(slaWarning)
+	regSummary.MustRegister(priorityMismatchCount, slaComplianceComputed, priorityChangeCount, reopenedCount)
+	regSummary.MustRegister(reassignmentCount, teamHandoffCount, teamTimeSeconds, olaCompliance, olaDuration)
+	regSummary.MustRegister(backlogAgeCount, oldestOpenAge, ticketFlowCount, backlogNetChange)
+	regSummary.MustRegister(holidaySyncLastSuccess, holidaySyncHolidays, holidaySyncErrors)
+	regSummary.MustRegister(configLastReloadSuccessful, configLastReloadSuccess, configHash)
 
 	regIncident.MustRegister(ticketDetailInfo)
 	regUserRequest.MustRegister(ticketDetailInfo)
+	regIncident.MustRegister(ticketTTODue, ticketTTRDue, ticketPriorityChanges, ticketReopens, ticketReassignments)
+	regUserRequest.MustRegister(ticketTTODue, ticketTTRDue, ticketPriorityChanges, ticketReopens, ticketReassignments)
 
 	// Data holders
 	var (
@@ -368,100 +291,122 @@ func main() {
 	)
 
 	// Parallel fetchers
+	ticketInterval := pollInterval(config.PollIntervals.Tickets)
 	go func() {
 		for {
-			tickets, _ := itop.FetchTicketsByClass("Incident")
+			tickets := fetchTicketsWithHistory("Incident")
 			muIncident.Lock()
 			incidentTickets = tickets
 			muIncident.Unlock()
-			time.Sleep(10 * time.Second)
+			time.Sleep(ticketInterval)
 		}
 	}()
 	go func() {
 		for {
-			tickets, _ := itop.FetchTicketsByClass("UserRequest")
+			tickets := fetchTicketsWithHistory("UserRequest")
 			muUserRequest.Lock()
 			userRequestTickets = tickets
 			muUserRequest.Unlock()
-			time.Sleep(10 * time.Second)
+			time.Sleep(ticketInterval)
 		}
 	}()
+	// Import iTop coverage windows as schedules, and the contract links to holiday calendars
+	if contractLinksNeeded(config) {
+		interval, _ := parseDuration(config.CoverageWindows.RefreshInterval)
+		go syncCoverageWindows(interval)
+	}
+	// Fetch priority, urgency, impact and status names from iTop
+	if config.Labels.FromITop {
+		interval, _ := parseDuration(config.Labels.RefreshInterval)
+		go syncITopLabels(interval)
+	}
+	// Team names for the reassignment and OLA metrics
+	go syncTeamNames(teamNamesRefreshInterval)
+	// Load holidays once, then reload them when the sync or an edit changes them
+	holidayStore.Reload()
+	go holidayStore.Watch(pollInterval(config.PollIntervals.HolidayFiles))
 	// Start holiday sync goroutine in parallel
 	go itop.SyncHolidaysToFile(
-		"holidays.txt",
-		10*time.Second,
+		config.HolidaysFile,
+		pollInterval(config.PollIntervals.HolidaySync),
+		holidayStore.Reload,
 	)
 
 	// Periodic summary metrics updater
 	go func() {
-		ticker := time.NewTicker(10 * time.Second)
+		ticker := time.NewTicker(pollInterval(config.PollIntervals.Summary))
 		defer ticker.Stop()
 		for {
 			allTickets := append([]itop.Ticket{}, incidentTickets...)
 			allTickets = append(allTickets, userRequestTickets...)
+			configMu.RLock()
 			updateSummaryMetrics(allTickets)
+			configMu.RUnlock()
 			<-ticker.C
 		}
 	}()
 
+	// Reload the config on SIGHUP
+	go watchReloadSignal()
+
 	// HTTP Handlers
 	http.Handle("/metrics", promhttp.HandlerFor(regSummary, promhttp.HandlerOpts{}))
+	http.HandleFunc("/debug/sla-mismatches", handleSLAMismatches)
+	http.HandleFunc("/debug/priority-mismatches", handlePriorityMismatches)
+	http.HandleFunc("/holidays.ics", handleHolidaysICS)
+	http.HandleFunc("/-/reload", handleReload)
 	http.HandleFunc("/incidents", func(w http.ResponseWriter, r *http.Request) {
 		regIncident.Unregister(ticketDetailInfo)
+		regIncident.Unregister(ticketTTODue)
+		regIncident.Unregister(ticketTTRDue)
+		regIncident.Unregister(ticketPriorityChanges)
+		regIncident.Unregister(ticketReopens)
+		regIncident.Unregister(ticketReassignments)
 		ticketDetailInfo.Reset()
+		ticketTTODue.Reset()
+		ticketTTRDue.Reset()
+		ticketPriorityChanges.Reset()
+		ticketReopens.Reset()
+		ticketReassignments.Reset()
+		configMu.RLock()
+		cals := newCalendarSet(holidayStore.Sets())
 		muIncident.RLock()
 		for _, t := range incidentTickets {
-			setTicketDetailMetric(t)
+			setTicketDetailMetric(t, cals)
 		}
 		muIncident.RUnlock()
-		regIncident.MustRegister(ticketDetailInfo)
+		configMu.RUnlock()
+		regIncident.MustRegister(ticketDetailInfo, ticketTTODue, ticketTTRDue, ticketPriorityChanges, ticketReopens, ticketReassignments)
 		promhttp.HandlerFor(regIncident, promhttp.HandlerOpts{}).ServeHTTP(w, r)
 	})
 	http.HandleFunc("/userrequests", func(w http.ResponseWriter, r *http.Request) {
 		regUserRequest.Unregister(ticketDetailInfo)
+		regUserRequest.Unregister(ticketTTODue)
+		regUserRequest.Unregister(ticketTTRDue)
+		regUserRequest.Unregister(ticketPriorityChanges)
+		regUserRequest.Unregister(ticketReopens)
+		regUserRequest.Unregister(ticketReassignments)
 		ticketDetailInfo.Reset()
+		ticketTTODue.Reset()
+		ticketTTRDue.Reset()
+		ticketPriorityChanges.Reset()
+		ticketReopens.Reset()
+		ticketReassignments.Reset()
+		configMu.RLock()
+		cals := newCalendarSet(holidayStore.Sets())
 		muUserRequest.RLock()
 		for _, t := range userRequestTickets {
-			setTicketDetailMetric(t)
+			setTicketDetailMetric(t, cals)
 		}
 		muUserRequest.RUnlock()
-		regUserRequest.MustRegister(ticketDetailInfo)
+		configMu.RUnlock()
+		regUserRequest.MustRegister(ticketDetailInfo, ticketTTODue, ticketTTRDue, ticketPriorityChanges, ticketReopens, ticketReassignments)
 		promhttp.HandlerFor(regUserRequest, promhttp.HandlerOpts{}).ServeHTTP(w, r)
 	})
 
-	fmt.Println("Exporter running on :9100/metrics, /incidents, /userrequests")
-	log.Fatal(http.ListenAndServe(":9100", nil))
-
-}
-
-func priorityLabel(id string) string {
-	switch id {
-	case "1":
-		return "Critical"
-	case "2":
-		return "High"
-	case "3":
-		return "Medium"
-	case "4":
-		return "Low"
-	default:
-		return id
-	}
-}
+	fmt.Printf("Exporter running on %s/metrics, /incidents, /userrequests, /debug/sla-mismatches, /debug/priority-mismatches, /holidays.ics, /-/reload\n", config.ListenAddress)
+	log.Fatal(http.ListenAndServe(config.ListenAddress, nil))
 
-func urgencyLabel(id string) string {
-	switch id {
-	case "1":
-		return "Critical"
-	case "2":
-		return "High"
-	case "3":
-		return "Medium"
-	case "4":
-		return "Low"
-	default:
-		return id
-	}
 }
 
 func parseDuration(s string) (time.Duration, error) {
@@ -482,20 +427,86 @@ var (
 	slaCompliance = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "itop_ticket_sla_compliance",
-			Help: "SLA compliance by class, priority, urgency, sla_type, sla_metric, status.",
+			Help: "SLA compliance by class, priority, urgency, sla_type, sla_metric, status, engine, schedule (business-hour only).",
 		},
-		[]string{"class", "priority", "urgency", "sla_type", "sla_metric", "status"},
+		[]string{"class", "priority", "urgency", "sla_type", "sla_metric", "status", "engine", "schedule"},
+	)
+
+	slaWarning = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "itop_ticket_sla_warning_count",
+			Help: "Number of tickets whose iTop stopwatch ran past its 75% escalation deadline, by class, priority, urgency, sla_metric.",
+		},
+		[]string{"class", "priority", "urgency", "sla_metric"},
+	)
+
+	slaMismatchCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "itop_ticket_sla_mismatch_count",
+			Help: "Number of tickets whose business-hour SLA verdict disagrees with iTop's sla_tto_passed/sla_ttr_passed flag, by class, service, sla_metric.",
+		},
+		[]string{"class", "service", "sla_metric"},
 	)
 )
 var ticketDetailInfo = prometheus.NewGaugeVec(
 	prometheus.GaugeOpts{
 		Name: "itop_ticket_detail_info",
-		Help: "Detail info per ticket, with all fields, time metrics in seconds, SLA compliance, and metric type.",
+		Help: "Detail info per ticket, with all fields, time metrics in seconds (empty for the itop engine), SLA compliance, metric type, SLA engine and business-hour schedule.",
 	},
 	[]string{
 		"id", "ref", "class", "title", "status", "priority", "urgency", "impact",
 		"service_name", "servicesubcategory_name", "agent_id_friendlyname", "team_id_friendlyname", "caller_id_friendlyname", "origin",
 		"start_date", "assignment_date", "resolution_date",
-		"time_to_response", "time_to_resolve", "type", "sla_metric", "sla_compliance",
+		"time_to_response", "time_to_resolve", "type", "sla_metric", "sla_compliance", "engine", "schedule",
 	},
 )
+
+var (
+	ticketTTODue = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "itop_ticket_tto_due_timestamp_seconds",
+			Help: "Time (Unix seconds) by which the ticket must be responded to, by id, ref, class, type (raw or business-hour).",
+		},
+		[]string{"id", "ref", "class", "type"},
+	)
+
+	ticketTTRDue = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "itop_ticket_ttr_due_timestamp_seconds",
+			Help: "Time (Unix seconds) by which the ticket must be resolved, by id, ref, class, type (raw or business-hour).",
+		},
+		[]string{"id", "ref", "class", "type"},
+	)
+)
+
+// Holiday sync status, read from the sync goroutine at scrape time
+var (
+	holidaySyncLastSuccess = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "itop_holiday_sync_last_success_timestamp_seconds",
+			Help: "Time (Unix seconds) of the last successful holiday sync from iTop, 0 if none yet.",
+		},
+		func() float64 {
+			if s := itop.GetHolidaySyncStatus(); !s.LastSuccess.IsZero() {
+				return float64(s.LastSuccess.Unix())
+			}
+			return 0
+		},
+	)
+
+	holidaySyncHolidays = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "itop_holiday_sync_holidays",
+			Help: "Number of holidays written by the last successful holiday sync.",
+		},
+		func() float64 { return float64(itop.GetHolidaySyncStatus().Holidays) },
+	)
+
+	holidaySyncErrors = prometheus.NewCounterFunc(
+		prometheus.CounterOpts{
+			Name: "itop_holiday_sync_errors_total",
+			Help: "Number of failed holiday syncs (fetch errors, empty results refused, write errors) since start.",
+		},
+		func() float64 { return float64(itop.GetHolidaySyncStatus().Errors) },
+	)
+)
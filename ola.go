@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"itop-sla-exporter/internal/itop"
+	"itop-sla-exporter/internal/utils"
+)
+
+// olaAnyPriority is the priority key of a team target that applies to the priorities not listed
+const olaAnyPriority = "*"
+
+// OLAConfig holds the time each team may hold a ticket, by team name, then priority code (or "*"),
+// as durations like "4h" measured on the ticket's business calendar
+type OLAConfig struct {
+	Targets map[string]map[string]string `yaml:"targets"`
+
+	targets map[string]map[string]time.Duration
+}
+
+// validateOLA parses the OLA targets
+func validateOLA(cfg *OLAConfig) error {
+	cfg.targets = make(map[string]map[string]time.Duration, len(cfg.Targets))
+	for team, byPriority := range cfg.Targets {
+		cfg.targets[team] = make(map[string]time.Duration, len(byPriority))
+		for priority, s := range byPriority {
+			d, err := parseDuration(s)
+			if err != nil {
+				return fmt.Errorf("ola.targets.%s.%s: %v", team, priority, err)
+			}
+			if d <= 0 {
+				return fmt.Errorf("ola.targets.%s.%s: target must be positive", team, priority)
+			}
+			cfg.targets[team][priority] = d
+		}
+	}
+	return nil
+}
+
+// olaTarget returns the OLA target of a team for a priority; ok is false when the team has none
+func olaTarget(team, priority string) (time.Duration, bool) {
+	byPriority := config.OLA.targets[team]
+	if d, ok := byPriority[priority]; ok {
+		return d, true
+	}
+	d, ok := byPriority[olaAnyPriority]
+	return d, ok
+}
+
+// olaSpanKey identifies a closed span a team held a ticket
+type olaSpanKey struct {
+	Class, ID, TeamID string
+	From              time.Time
+}
+
+// olaObserved holds the closed spans already observed in olaDuration. The histogram's series are counters,
+// so it is never reset: each closed span is observed once, by the first summary update that sees it.
+// Only the summary updater uses it.
+var olaObserved = make(map[olaSpanKey]bool)
+
+// addOLAMetrics evaluates each span a team held the ticket against the team's target.
+// A span still running only counts once it has passed its target. Unlike the SLA, a span held only
+// outside business hours (business time 0) complies: the team had no working time to act.
+func addOLAMetrics(t itop.Ticket, cal *utils.BusinessCalendar, now time.Time) {
+	prio := priorityLabel(t.Priority)
+	sched := cal.Schedule().Name
+	for _, p := range teamPeriods(t, now) {
+		team := teamName(p.TeamID)
+		target, ok := olaTarget(team, t.Priority)
+		if !ok {
+			continue
+		}
+		raw := p.To.Sub(p.From).Seconds()
+		bh := cal.Duration(p.From, p.To).Seconds()
+		observe := false
+		if key := (olaSpanKey{t.Class, t.ID, p.TeamID, p.From}); !p.Open && !olaObserved[key] {
+			olaObserved[key] = true
+			observe = true
+		}
+		add := func(slaType, schedule string, measured float64) {
+			comply := measured <= target.Seconds()
+			if p.Open && comply {
+				return
+			}
+			c := boolToFloat(comply)
+			olaCompliance.WithLabelValues(t.Class, team, prio, slaType, "comply", schedule).Add(c)
+			olaCompliance.WithLabelValues(t.Class, team, prio, slaType, "violate", schedule).Add(1.0 - c)
+			if observe {
+				olaDuration.WithLabelValues(t.Class, team, prio, slaType).Observe(measured)
+			}
+		}
+		add("raw", "", raw)
+		add("business-hour", sched, bh)
+	}
+}
+
+var (
+	olaCompliance = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "itop_team_ola_compliance_count",
+			Help: "OLA compliance of the spans teams held tickets, by class, team, priority, sla_type, status, schedule.",
+		},
+		[]string{"class", "team", "priority", "sla_type", "status", "schedule"},
+	)
+
+	olaDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "itop_team_ola_duration_seconds",
+			Help:    "Time teams held tickets before handing them off or resolving them, for teams with an OLA target, by class, team, priority, sla_type. Each span is observed once, when first seen closed.",
+			Buckets: []float64{900, 1800, 3600, 2 * 3600, 4 * 3600, 8 * 3600, 24 * 3600, 3 * 24 * 3600, 7 * 24 * 3600},
+		},
+		[]string{"class", "team", "priority", "sla_type"},
+	)
+)
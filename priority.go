@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"itop-sla-exporter/internal/itop"
+	"itop-sla-exporter/internal/utils"
+)
+
+// PriorityMatrixConfig is the impact×urgency→priority matrix iTop applies, used to detect overridden priorities
+type PriorityMatrixConfig struct {
+	// Matrix maps impact code, then urgency code, to priority code; it replaces the standard iTop matrix
+	Matrix map[string]map[string]string `yaml:"matrix"`
+	// AuditSLA also evaluates the exporter's SLA with the computed priority (itop_ticket_sla_compliance_computed_priority_count)
+	AuditSLA bool `yaml:"audit_sla"`
+}
+
+// defaultPriorityMatrix is iTop's standard matrix (impact 1 = department, 2 = service, 3 = person)
+var defaultPriorityMatrix = map[string]map[string]string{
+	"1": {"1": "1", "2": "1", "3": "2", "4": "3"},
+	"2": {"1": "1", "2": "2", "3": "3", "4": "4"},
+	"3": {"1": "2", "2": "3", "3": "4", "4": "4"},
+}
+
+// validatePriorityMatrix defaults the matrix and checks that every cell names a priority
+func validatePriorityMatrix(cfg *PriorityMatrixConfig) error {
+	if len(cfg.Matrix) == 0 {
+		cfg.Matrix = defaultPriorityMatrix
+	}
+	for impact, row := range cfg.Matrix {
+		for urgency, priority := range row {
+			if priority == "" {
+				return fmt.Errorf("priority_matrix.matrix.%s.%s: priority is empty", impact, urgency)
+			}
+		}
+	}
+	return nil
+}
+
+// computedPriority returns the priority the matrix gives to a ticket's impact and urgency; ok is false when it has none
+func computedPriority(t itop.Ticket) (string, bool) {
+	p, ok := config.PriorityMatrix.Matrix[t.Impact][t.Urgency]
+	return p, ok
+}
+
+// priorityMismatch is one ticket whose stored priority differs from the one computed from impact and urgency
+type priorityMismatch struct {
+	ID               string `json:"id"`
+	Ref              string `json:"ref"`
+	Class            string `json:"class"`
+	Service          string `json:"service"`
+	Team             string `json:"team"`
+	Impact           string `json:"impact"`
+	Urgency          string `json:"urgency"`
+	StoredPriority   string `json:"stored_priority"`
+	ComputedPriority string `json:"computed_priority"`
+	StoredCode       string `json:"stored_priority_code"`
+	ComputedCode     string `json:"computed_priority_code"`
+}
+
+var (
+	priorityMismatches   []priorityMismatch
+	priorityMismatchesMu sync.RWMutex
+)
+
+// checkPriority compares the stored priority of a ticket with the computed one
+func checkPriority(t itop.Ticket) (priorityMismatch, bool) {
+	computed, ok := computedPriority(t)
+	if !ok || t.Priority == "" || computed == t.Priority {
+		return priorityMismatch{}, false
+	}
+	return priorityMismatch{
+		ID:               t.ID,
+		Ref:              t.Ref,
+		Class:            t.Class,
+		Service:          t.Service,
+		Team:             t.Team,
+		Impact:           impactLabel(t.Impact),
+		Urgency:          urgencyLabel(t.Urgency),
+		StoredPriority:   priorityLabel(t.Priority),
+		ComputedPriority: priorityLabel(computed),
+		StoredCode:       t.Priority,
+		ComputedCode:     computed,
+	}, true
+}
+
+// auditSLA evaluates the exporter's SLA of a ticket as if it had the computed priority
+func auditSLA(t itop.Ticket, cal *utils.BusinessCalendar) {
+	computed, ok := computedPriority(t)
+	if !ok {
+		return
+	}
+	audited := t
+	audited.Priority = computed
+	audited.History = nil
+	res := evaluateSLA(audited, cal)
+	prio, urg, sched := priorityLabel(computed), urgencyLabel(t.Urgency), cal.Schedule().Name
+	add := func(slaType, slaMetric, schedule string, comply bool) {
+		c := boolToFloat(comply)
+		slaComplianceComputed.WithLabelValues(t.Class, prio, urg, slaType, slaMetric, "comply", schedule).Add(c)
+		slaComplianceComputed.WithLabelValues(t.Class, prio, urg, slaType, slaMetric, "violate", schedule).Add(1.0 - c)
+	}
+	add("raw", "response", "", res.ComplyResponseRaw)
+	add("raw", "resolve", "", res.ComplyResolveRaw)
+	add("business-hour", "response", sched, res.ComplyResponseBH)
+	add("business-hour", "resolve", sched, res.ComplyResolveBH)
+}
+
+// setPriorityMismatches replaces the priority mismatch report and its count metric
+func setPriorityMismatches(list []priorityMismatch) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Class != list[j].Class {
+			return list[i].Class < list[j].Class
+		}
+		return list[i].Ref < list[j].Ref
+	})
+	priorityMismatchCount.Reset()
+	for _, m := range list {
+		priorityMismatchCount.WithLabelValues(m.Class, m.StoredPriority, m.ComputedPriority).Inc()
+	}
+	priorityMismatchesMu.Lock()
+	priorityMismatches = list
+	priorityMismatchesMu.Unlock()
+}
+
+// handlePriorityMismatches serves the last priority mismatch report as JSON
+func handlePriorityMismatches(w http.ResponseWriter, r *http.Request) {
+	priorityMismatchesMu.RLock()
+	list := priorityMismatches
+	priorityMismatchesMu.RUnlock()
+	if list == nil {
+		list = []priorityMismatch{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count":      len(list),
+		"mismatches": list,
+	})
+}
+
+var (
+	priorityMismatchCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "itop_ticket_priority_mismatch_count",
+			Help: "Number of tickets whose stored priority differs from the impact×urgency matrix, by class, stored_priority, computed_priority.",
+		},
+		[]string{"class", "stored_priority", "computed_priority"},
+	)
+
+	slaComplianceComputed = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "itop_ticket_sla_compliance_computed_priority_count",
+			Help: "SLA compliance evaluated by the exporter with the priority computed from impact and urgency (audit), by class, priority, urgency, sla_type, sla_metric, status, schedule.",
+		},
+		[]string{"class", "priority", "urgency", "sla_type", "sla_metric", "status", "schedule"},
+	)
+)
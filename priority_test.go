@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"itop-sla-exporter/internal/itop"
+)
+
+// TestComputedPriorityStandardMatrix checks the default matrix against iTop's ComputePriority
+func TestComputedPriorityStandardMatrix(t *testing.T) {
+	var cfg PriorityMatrixConfig
+	if err := validatePriorityMatrix(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	old := config.PriorityMatrix
+	config.PriorityMatrix = cfg
+	defer func() { config.PriorityMatrix = old }()
+
+	tests := []struct {
+		impact, urgency, want string
+	}{
+		{"1", "1", "1"}, {"1", "2", "1"}, {"1", "3", "2"}, {"1", "4", "3"}, // department
+		{"2", "1", "1"}, {"2", "2", "2"}, {"2", "3", "3"}, {"2", "4", "4"}, // service
+		{"3", "1", "2"}, {"3", "2", "3"}, {"3", "3", "4"}, {"3", "4", "4"}, // person
+	}
+	for _, tt := range tests {
+		got, ok := computedPriority(itop.Ticket{Impact: tt.impact, Urgency: tt.urgency})
+		if !ok || got != tt.want {
+			t.Errorf("impact %s, urgency %s: got %q (ok %v), want %q", tt.impact, tt.urgency, got, ok, tt.want)
+		}
+	}
+	if p, ok := computedPriority(itop.Ticket{Impact: "9", Urgency: "1"}); ok {
+		t.Errorf("unknown impact: got %q, want none", p)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"itop-sla-exporter/internal/itop"
+	"itop-sla-exporter/internal/utils"
+)
+
+// ReassignmentConfig enables the reassignment metrics, which need the team_id/agent_id history
+type ReassignmentConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// teamNamesRefreshInterval is how often all team names are fetched; teams rarely change, and the
+// names of the tickets' current teams are learned on every poll anyway
+const teamNamesRefreshInterval = time.Hour
+
+var (
+	teamNames   = make(map[string]string) // team id → name, from iTop and from the tickets' current team
+	teamNamesMu sync.RWMutex
+)
+
+// teamNamesNeeded reports whether a feature resolves team ids from the history
+func teamNamesNeeded(cfg Config) bool {
+	return cfg.Reassignment.Enabled || len(cfg.OLA.Targets) > 0
+}
+
+// learnTeamNames records the names of the tickets' current teams
+func learnTeamNames(tickets []itop.Ticket) {
+	teamNamesMu.Lock()
+	defer teamNamesMu.Unlock()
+	for _, t := range tickets {
+		if assigned(t.TeamID) && t.Team != "" {
+			teamNames[t.TeamID] = t.Team
+		}
+	}
+}
+
+// refreshTeamNames fetches the names of all teams from iTop, for hand-offs to or from teams no current ticket has
+func refreshTeamNames() error {
+	names, err := itop.FetchTeamNames()
+	if err != nil {
+		return err
+	}
+	teamNamesMu.Lock()
+	defer teamNamesMu.Unlock()
+	for id, name := range names {
+		teamNames[id] = name
+	}
+	return nil
+}
+
+// syncTeamNames periodically refreshes the team names while a feature needs them; known names are kept on error
+func syncTeamNames(interval time.Duration) {
+	for {
+		if teamNamesNeeded(currentConfig()) {
+			if err := refreshTeamNames(); err != nil {
+				log.Printf("Failed to fetch teams: %v", err)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+// teamName returns the name of a team id, or the id when the team is unknown
+func teamName(id string) string {
+	teamNamesMu.RLock()
+	defer teamNamesMu.RUnlock()
+	if name, ok := teamNames[id]; ok {
+		return name
+	}
+	return id
+}
+
+// assigned reports whether an external key value (team_id, agent_id) points to an object; iTop stores 0 for none
+func assigned(id string) bool {
+	return id != "" && id != "0"
+}
+
+// reassignments returns the changes of team_id or agent_id from one team/agent to another.
+// The first assignment of a ticket is not a reassignment.
+func reassignments(t itop.Ticket, attribute string) []itop.AttributeChange {
+	var list []itop.AttributeChange
+	for _, c := range t.Changes(attribute) {
+		if assigned(c.OldValue) && assigned(c.NewValue) && c.OldValue != c.NewValue {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// teamPeriod is a span of time a ticket spent with one team
+type teamPeriod struct {
+	TeamID   string
+	From, To time.Time
+	Open     bool // the team still has the unresolved ticket (To is now)
+}
+
+// teamPeriods returns the teams a ticket was with, from its start date to its resolution (or now while open).
+// Spans without a team are left out. now must be in the frame of the ticket dates (itopNow).
+func teamPeriods(t itop.Ticket, now time.Time) []teamPeriod {
+	if t.StartDate.IsZero() {
+		return nil
+	}
+	end := t.ResolutionDate
+	if end.IsZero() {
+		end = now
+	}
+	clamp := func(d time.Time) time.Time {
+		if d.Before(t.StartDate) {
+			return t.StartDate
+		}
+		if d.After(end) {
+			return end
+		}
+		return d
+	}
+	team, from := t.TeamID, t.StartDate
+	changes := t.Changes("team_id")
+	if len(changes) > 0 {
+		team = changes[0].OldValue
+	}
+	var periods []teamPeriod
+	for _, c := range changes {
+		to := clamp(c.Date)
+		if assigned(team) && to.After(from) {
+			periods = append(periods, teamPeriod{team, from, to, false})
+		}
+		team, from = c.NewValue, to
+	}
+	if assigned(team) && end.After(from) {
+		periods = append(periods, teamPeriod{team, from, end, t.ResolutionDate.IsZero()})
+	}
+	return periods
+}
+
+// addReassignmentMetrics adds a ticket's reassignments, hand-offs and time per team to the summary metrics
+func addReassignmentMetrics(t itop.Ticket, cal *utils.BusinessCalendar, now time.Time) {
+	teamChanges := reassignments(t, "team_id")
+	reassignmentCount.WithLabelValues(t.Class, "team", t.Team, t.Service).Add(float64(len(teamChanges)))
+	reassignmentCount.WithLabelValues(t.Class, "agent", t.Team, t.Service).Add(float64(len(reassignments(t, "agent_id"))))
+	for _, c := range teamChanges {
+		teamHandoffCount.WithLabelValues(t.Class, teamName(c.OldValue), teamName(c.NewValue)).Inc()
+	}
+	sched := cal.Schedule().Name
+	for _, p := range teamPeriods(t, now) {
+		name := teamName(p.TeamID)
+		teamTimeSeconds.WithLabelValues(t.Class, name, "raw", "").Add(p.To.Sub(p.From).Seconds())
+		teamTimeSeconds.WithLabelValues(t.Class, name, "business-hour", sched).Add(cal.Duration(p.From, p.To).Seconds())
+	}
+}
+
+// setReassignmentMetric exports the reassignment counts of a ticket
+func setReassignmentMetric(t itop.Ticket) {
+	if !config.Reassignment.Enabled {
+		return
+	}
+	ticketReassignments.WithLabelValues(t.ID, t.Ref, t.Class, "team").Set(float64(len(reassignments(t, "team_id"))))
+	ticketReassignments.WithLabelValues(t.ID, t.Ref, t.Class, "agent").Set(float64(len(reassignments(t, "agent_id"))))
+}
+
+var (
+	reassignmentCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "itop_ticket_reassignment_count",
+			Help: "Number of reassignments from one team or agent to another across tickets, by class, kind (team, agent), current team, service.",
+		},
+		[]string{"class", "kind", "team", "service"},
+	)
+
+	teamHandoffCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "itop_team_handoff_count",
+			Help: "Number of tickets handed off between two teams, by class, from_team, to_team.",
+		},
+		[]string{"class", "from_team", "to_team"},
+	)
+
+	teamTimeSeconds = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "itop_team_ticket_time_seconds",
+			Help: "Time tickets spent with a team until resolution (or now while open), by class, team, sla_type, schedule.",
+		},
+		[]string{"class", "team", "sla_type", "schedule"},
+	)
+
+	ticketReassignments = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "itop_ticket_reassignments",
+			Help: "Number of reassignments of a ticket, by id, ref, class, kind (team, agent).",
+		},
+		[]string{"id", "ref", "class", "kind"},
+	)
+)
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// slaMismatch is one ticket where the exporter's business-hour verdict disagrees with iTop's sla_*_passed flag
+type slaMismatch struct {
+	ID              string  `json:"id"`
+	Ref             string  `json:"ref"`
+	Class           string  `json:"class"`
+	Service         string  `json:"service"`
+	Priority        string  `json:"priority"`
+	SLAMetric       string  `json:"sla_metric"`
+	ExporterVerdict string  `json:"exporter_verdict"`
+	ITopPassed      string  `json:"itop_passed"`
+	MeasuredSeconds float64 `json:"measured_seconds"`
+	DeadlineSeconds float64 `json:"deadline_seconds"`
+}
+
+var (
+	slaMismatches   []slaMismatch
+	slaMismatchesMu sync.RWMutex
+)
+
+// parseITopPassed interprets iTop's sla_tto_passed/sla_ttr_passed value; ok is false when iTop gave no verdict
+func parseITopPassed(v string) (passed bool, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "1", "yes", "true":
+		return true, true
+	case "0", "no", "false":
+		return false, true
+	}
+	return false, false
+}
+
+// reconcileSLA compares one SLA metric of a ticket with iTop's passed flag.
+// Only tickets for which both sides have a verdict (known deadline, metric reached) are compared. Whether
+// the metric was reached is read from its raw duration: a ticket handled entirely outside business hours
+// measures 0 business time, yet has a verdict (see withinDeadline).
+func reconcileSLA(base slaMismatch, metric, itopPassed string, measured, raw float64, deadlineSeconds float64, comply bool) (slaMismatch, bool) {
+	passed, ok := parseITopPassed(itopPassed)
+	if !ok || deadlineSeconds <= 0 || raw <= 0 {
+		return slaMismatch{}, false
+	}
+	// iTop "passed" means the deadline was exceeded, so it agrees with a "violate" verdict
+	if passed != comply {
+		return slaMismatch{}, false
+	}
+	base.SLAMetric = metric
+	base.ExporterVerdict = complianceStatus(comply)
+	base.ITopPassed = itopPassed
+	base.MeasuredSeconds = measured
+	base.DeadlineSeconds = deadlineSeconds
+	return base, true
+}
+
+// setSLAMismatches replaces the reconciliation report and the mismatch count metric
+func setSLAMismatches(list []slaMismatch) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Class != list[j].Class {
+			return list[i].Class < list[j].Class
+		}
+		if list[i].Ref != list[j].Ref {
+			return list[i].Ref < list[j].Ref
+		}
+		return list[i].SLAMetric < list[j].SLAMetric
+	})
+	slaMismatchCount.Reset()
+	for _, m := range list {
+		slaMismatchCount.WithLabelValues(m.Class, m.Service, m.SLAMetric).Inc()
+	}
+	slaMismatchesMu.Lock()
+	slaMismatches = list
+	slaMismatchesMu.Unlock()
+}
+
+// handleSLAMismatches serves the last reconciliation report as JSON
+func handleSLAMismatches(w http.ResponseWriter, r *http.Request) {
+	slaMismatchesMu.RLock()
+	list := slaMismatches
+	slaMismatchesMu.RUnlock()
+	if list == nil {
+		list = []slaMismatch{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count":      len(list),
+		"mismatches": list,
+	})
+}
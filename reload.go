@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// cliOpts are the config path and overrides given at startup, reused by every reload
+	cliOpts cliOptions
+	// reloadMu serializes reloads
+	reloadMu sync.Mutex
+)
+
+// reloadConfig re-reads the config file. An invalid config is rejected and the running one kept;
+// the SLT cache and the fetched tickets are left untouched either way.
+func reloadConfig() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	old := currentConfig()
+	if err := loadConfig(cliOpts.ConfigPath, cliOpts.Overrides); err != nil {
+		log.Printf("Config reload failed, keeping the running config: %v", err)
+		return err
+	}
+	cfg := currentConfig()
+	for _, key := range restartOnlyChanges(old, cfg) {
+		log.Printf("Config reload: %s changed, it takes effect after a restart", key)
+	}
+	holidayStore.Reload()
+	log.Printf("Config reloaded from %s", cliOpts.ConfigPath)
+	return nil
+}
+
+// restartOnlyChanges lists the settings that are only read at startup and differ between two configs
+func restartOnlyChanges(old, cfg Config) []string {
+	var keys []string
+	if old.ListenAddress != cfg.ListenAddress {
+		keys = append(keys, "listen_address")
+	}
+	if old.ITopAPIVersion != cfg.ITopAPIVersion {
+		keys = append(keys, "itop_api_version")
+	}
+	if old.PollIntervals != cfg.PollIntervals {
+		keys = append(keys, "poll_intervals")
+	}
+	if old.HolidaysFile != cfg.HolidaysFile {
+		keys = append(keys, "holidays_file")
+	}
+	if !reflect.DeepEqual(old.CoverageWindows, cfg.CoverageWindows) {
+		keys = append(keys, "coverage_windows")
+	}
+	if old.HolidayCalendars.UseITopLinkage != cfg.HolidayCalendars.UseITopLinkage && !contractLinksNeeded(old) {
+		keys = append(keys, "holiday_calendars.use_itop_linkage")
+	}
+	if old.Labels.FromITop != cfg.Labels.FromITop || old.Labels.RefreshInterval != cfg.Labels.RefreshInterval {
+		keys = append(keys, "labels.from_itop/refresh_interval")
+	}
+	return keys
+}
+
+// watchReloadSignal reloads the config on SIGHUP
+func watchReloadSignal() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	for range ch {
+		reloadConfig()
+	}
+}
+
+// handleReload reloads the config on POST /-/reload
+func handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		w.Header().Set("Allow", "POST, PUT")
+		http.Error(w, "use POST or PUT to reload the config", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := reloadConfig(); err != nil {
+		http.Error(w, fmt.Sprintf("failed to reload config: %v", err), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, "config reloaded")
+}
+
+var (
+	configLastReloadSuccessful = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "itop_exporter_config_last_reload_successful",
+			Help: "Whether the last config load or reload succeeded (1) or was rejected (0).",
+		},
+	)
+
+	configLastReloadSuccess = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "itop_exporter_config_last_reload_success_timestamp_seconds",
+			Help: "Time (Unix seconds) of the last successful config load or reload.",
+		},
+	)
+
+	configHash = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "itop_exporter_config_hash",
+			Help: "Always 1, with the SHA-256 of the running config file as the sha256 label.",
+		},
+		[]string{"sha256"},
+	)
+)
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"itop-sla-exporter/internal/itop"
+)
+
+// Response definitions: which event stops a ticket's time to response
+const (
+	responseAssignment   = "assignment"    // assignment_date (default)
+	responsePublicLog    = "public_log"    // first public_log entry not written by the caller
+	responseStatusChange = "status_change" // first status change in the ticket history
+)
+
+// validateResponseDefinitions checks the response definition of each class
+func validateResponseDefinitions(defs map[string]string) error {
+	for class, def := range defs {
+		switch def {
+		case responseAssignment, responsePublicLog, responseStatusChange:
+		default:
+			return fmt.Errorf("response_definition.%s: invalid definition %q (expected %s, %s or %s)", class, def,
+				responseAssignment, responsePublicLog, responseStatusChange)
+		}
+	}
+	return nil
+}
+
+func responseDefinition(cfg Config, class string) string {
+	if def, ok := cfg.ResponseDefinition[class]; ok {
+		return def
+	}
+	return responseAssignment
+}
+
+// respondedAt returns when a ticket was responded to under its class's response definition (zero: not yet)
+func respondedAt(t itop.Ticket) time.Time {
+	switch responseDefinition(config, t.Class) {
+	case responsePublicLog:
+		return t.FirstAgentLog
+	case responseStatusChange:
+		if changes := t.Changes("status"); len(changes) > 0 {
+			return changes[0].Date
+		}
+		return time.Time{}
+	}
+	return t.AssignmentDate
+}
+
+// responseFields returns the extra output_fields a class's response definition needs (called by the fetchers, outside configMu)
+func responseFields(class string) []string {
+	switch responseDefinition(currentConfig(), class) {
+	case responsePublicLog:
+		return []string{itop.PublicLogOutputField}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"itop-sla-exporter/internal/itop"
+	"itop-sla-exporter/internal/utils"
+)
+
+// defaultScheduleName is used for the schedule built from work_hours when no schedules are configured
+const defaultScheduleName = "default"
+
+// ScheduleConfig is one named business-hour schedule in the YAML config
+type ScheduleConfig struct {
+	// Hours are the windows used on every working day, e.g. ["08:00-12:00", "13:00-17:00"]
+	Hours []string `yaml:"hours"`
+	// WorkingDays lists the days Hours apply to (default monday to friday), e.g. [sunday, monday, tuesday, wednesday, thursday]
+	WorkingDays []string `yaml:"working_days"`
+	// Days overrides the windows per weekday (e.g. saturday: ["08:00-12:00"], friday: [])
+	Days map[string][]string `yaml:"days"`
+	// AlwaysOn counts every hour of every day (24x7)
+	AlwaysOn bool `yaml:"always_on"`
+}
+
+// ScheduleAssignment maps tickets to a schedule; all fields set on a rule must match
+type ScheduleAssignment struct {
+	Team         string `yaml:"team"`
+	Service      string `yaml:"service"`
+	Organization string `yaml:"organization"`
+	Priority     string `yaml:"priority"`
+	Schedule     string `yaml:"schedule"`
+}
+
+// schedules holds the compiled schedules of the loaded config, by name (guarded by configMu)
+var schedules map[string]*utils.Schedule
+
+var weekdayNames = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// compileSchedules converts the schedule section of the config, or work_hours when it is empty
+func compileSchedules(cfg *Config) (map[string]*utils.Schedule, error) {
+	compiled := make(map[string]*utils.Schedule)
+	if len(cfg.Schedules) == 0 {
+		w, err := utils.ParseTimeWindow(cfg.WorkHours.Start + "-" + cfg.WorkHours.End)
+		if err != nil {
+			return nil, fmt.Errorf("work_hours: %v", err)
+		}
+		days, err := parseWeekdays(cfg.WorkHours.WorkingDays)
+		if err != nil {
+			return nil, fmt.Errorf("work_hours: %v", err)
+		}
+		compiled[defaultScheduleName] = utils.NewSchedule(defaultScheduleName, days, []utils.TimeWindow{w})
+		cfg.DefaultSchedule = defaultScheduleName
+		return compiled, nil
+	}
+	for name, sc := range cfg.Schedules {
+		s, err := compileSchedule(name, sc)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %v", name, err)
+		}
+		compiled[name] = s
+	}
+	if cfg.DefaultSchedule == "" {
+		return nil, fmt.Errorf("default_schedule is required when schedules are defined")
+	}
+	if _, ok := compiled[cfg.DefaultSchedule]; !ok {
+		return nil, fmt.Errorf("default_schedule %q is not defined", cfg.DefaultSchedule)
+	}
+	for i, a := range cfg.ScheduleAssignments {
+		if _, ok := compiled[a.Schedule]; !ok {
+			return nil, fmt.Errorf("schedule_assignments[%d]: schedule %q is not defined", i, a.Schedule)
+		}
+	}
+	return compiled, nil
+}
+
+func compileSchedule(name string, sc ScheduleConfig) (*utils.Schedule, error) {
+	if sc.AlwaysOn {
+		return &utils.Schedule{Name: name, AlwaysOn: true}, nil
+	}
+	hours, err := parseWindows(sc.Hours)
+	if err != nil {
+		return nil, err
+	}
+	days, err := parseWeekdays(sc.WorkingDays)
+	if err != nil {
+		return nil, err
+	}
+	s := utils.NewSchedule(name, days, hours)
+	for day, list := range sc.Days {
+		wd, ok := weekdayNames[strings.ToLower(day)]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday %q", day)
+		}
+		windows, err := parseWindows(list)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", day, err)
+		}
+		s.Days[wd] = windows
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// parseWeekdays converts weekday names; an unset list means monday to friday
+func parseWeekdays(names []string) ([]time.Weekday, error) {
+	if names == nil {
+		return utils.WeekdaysMondayToFriday, nil
+	}
+	var days []time.Weekday
+	for _, name := range names {
+		wd, ok := weekdayNames[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday %q", name)
+		}
+		days = append(days, wd)
+	}
+	return days, nil
+}
+
+func parseWindows(list []string) ([]utils.TimeWindow, error) {
+	var windows []utils.TimeWindow
+	for _, s := range list {
+		w, err := utils.ParseTimeWindow(s)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// scheduleFor returns the schedule of a ticket: the first matching assignment, else the iTop coverage
+// window of its service (when imported), else the default schedule
+func scheduleFor(t itop.Ticket) *utils.Schedule {
+	for _, a := range config.ScheduleAssignments {
+		if a.matches(t) {
+			return schedules[a.Schedule]
+		}
+	}
+	if config.CoverageWindows.Enabled {
+		if s := coverageScheduleFor(t.Organization, t.Service); s != nil {
+			return s
+		}
+	}
+	return schedules[config.DefaultSchedule]
+}
+
+func (a ScheduleAssignment) matches(t itop.Ticket) bool {
+	if a.Team == "" && a.Service == "" && a.Organization == "" && a.Priority == "" {
+		return false
+	}
+	if a.Team != "" && !strings.EqualFold(a.Team, t.Team) {
+		return false
+	}
+	if a.Service != "" && !strings.EqualFold(a.Service, t.Service) {
+		return false
+	}
+	if a.Organization != "" && !strings.EqualFold(a.Organization, t.Organization) {
+		return false
+	}
+	if a.Priority != "" && a.Priority != t.Priority && !strings.EqualFold(a.Priority, priorityLabel(t.Priority)) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"time"
+
+	"itop-sla-exporter/internal/itop"
+	"itop-sla-exporter/internal/utils"
+)
+
+// SLA engines selectable with sla_engine
+const (
+	engineExporter = "exporter"
+	engineITop     = "itop"
+	engineBoth     = "both"
+)
+
+// slaEngines returns the engines whose verdicts are exported for the configured sla_engine
+func slaEngines() []string {
+	switch config.SLAEngine {
+	case engineITop:
+		return []string{engineITop}
+	case engineBoth:
+		return []string{engineExporter, engineITop}
+	}
+	return []string{engineExporter}
+}
+
+// stopwatchFields returns the extra output_fields needed by the iTop engine (called by the fetchers, outside configMu)
+func stopwatchFields() []string {
+	engine := currentConfig().SLAEngine
+	if engine == engineITop || engine == engineBoth {
+		return itop.StopwatchOutputFields
+	}
+	return nil
+}
+
+// slaResult holds the measured durations (in seconds) and the compliance verdicts of one ticket
+type slaResult struct {
+	TTORaw           float64
+	TTRRaw           float64
+	TTOBH            float64
+	TTRBH            float64
+	ResponseDeadline time.Duration
+	ResolveDeadline  time.Duration
+	ResolveStart     time.Time // TTR start (start date, or last reopen with sla_policy.reopen: restart)
+	ResolveEnd       time.Time // TTR stop, zero while unresolved
+
+	ComplyResponseRaw bool
+	ComplyResolveRaw  bool
+	ComplyResponseBH  bool
+	ComplyResolveBH   bool
+}
+
+// evaluateSLA measures a ticket's TTO/TTR in raw and business-hour mode and compares them with the SLT from iTop
+func evaluateSLA(t itop.Ticket, cal *utils.BusinessCalendar) slaResult {
+	var r slaResult
+	// TTO stops at the event chosen by response_definition (assignment by default)
+	if responded := respondedAt(t); !t.StartDate.IsZero() && !responded.IsZero() {
+		r.TTORaw = responded.Sub(t.StartDate).Seconds()
+		r.TTOBH = cal.Duration(t.StartDate, responded).Seconds()
+	}
+	// TTR window, moved by sla_policy.reopen for reopened tickets
+	r.ResolveStart, r.ResolveEnd = resolveWindow(t)
+	if !r.ResolveStart.IsZero() && !r.ResolveEnd.IsZero() {
+		r.TTRRaw = r.ResolveEnd.Sub(r.ResolveStart).Seconds()
+		r.TTRBH = cal.Duration(r.ResolveStart, r.ResolveEnd).Seconds()
+	}
+
+	// SLA deadline from iTop (not config, now cached), for the priority chosen by sla_policy.priority
+	r.ResponseDeadline, r.ResolveDeadline = applicableSLT(t, cal)
+
+	r.ComplyResponseRaw = withinDeadline(r.TTORaw, r.TTORaw, r.ResponseDeadline)
+	r.ComplyResolveRaw = withinDeadline(r.TTRRaw, r.TTRRaw, r.ResolveDeadline)
+	r.ComplyResponseBH = withinDeadline(r.TTOBH, r.TTORaw, r.ResponseDeadline)
+	r.ComplyResolveBH = withinDeadline(r.TTRBH, r.TTRRaw, r.ResolveDeadline)
+	return r
+}
+
+// withinDeadline reports whether a measured duration meets the deadline.
+// Perbaikan: jika measured == 0 tapi raw <= deadline, tetap comply (response/resolve sebelum jam kerja)
+func withinDeadline(measured, raw float64, deadline time.Duration) bool {
+	if deadline <= 0 {
+		return false
+	}
+	if measured > 0 {
+		return measured <= deadline.Seconds()
+	}
+	return raw > 0 && raw <= deadline.Seconds()
+}
+
+// itopSLAResult is the verdict of iTop's own TTO/TTR stopwatches
+type itopSLAResult struct {
+	ComplyResponse  bool
+	ComplyResolve   bool
+	WarningResponse bool
+	WarningResolve  bool
+}
+
+// evaluateITopSLA trusts iTop's stopwatches: the sla_tto_passed/sla_ttr_passed flags for the verdict, and the
+// 75% escalation deadlines for the warning. now must be in the frame of the ticket dates (itopNow).
+func evaluateITopSLA(t itop.Ticket, now time.Time) itopSLAResult {
+	var r itopSLAResult
+	r.ComplyResponse = stopwatchComply(t.AssignmentDate, t.SLATTOPassed)
+	r.ComplyResolve = stopwatchComply(t.ResolutionDate, t.SLATTRPassed)
+	r.WarningResponse = escalationPassed(t.AssignmentDate, t.TTOEscalation, now)
+	r.WarningResolve = escalationPassed(t.ResolutionDate, t.TTREscalation, now)
+	return r
+}
+
+// stopwatchComply reports whether a stopwatch was stopped (at reached) without iTop flagging it as passed
+func stopwatchComply(reached time.Time, passedFlag string) bool {
+	if reached.IsZero() {
+		return false
+	}
+	passed, ok := parseITopPassed(passedFlag)
+	return ok && !passed
+}
+
+// escalationPassed reports whether a stopwatch ran past its 75% deadline, until it stopped (reached) or now
+func escalationPassed(reached, deadline, now time.Time) bool {
+	if deadline.IsZero() {
+		return false
+	}
+	if reached.IsZero() {
+		reached = now
+	}
+	return reached.After(deadline)
+}
+
+// addCompliance adds one ticket to the comply/violate pair of itop_ticket_sla_compliance
+func addCompliance(class, prio, urg, slaType, slaMetric, engine, schedule string, comply bool) {
+	c := boolToFloat(comply)
+	slaCompliance.WithLabelValues(class, prio, urg, slaType, slaMetric, "comply", engine, schedule).Add(c)
+	slaCompliance.WithLabelValues(class, prio, urg, slaType, slaMetric, "violate", engine, schedule).Add(1.0 - c)
+}
+
+// complianceStatus converts a verdict to the "comply"/"violate" label value
+func complianceStatus(comply bool) string {
+	if comply {
+		return "comply"
+	}
+	return "violate"
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1.0
+	}
+	return 0.0
+}